@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchLine is one entry of a --batch file. Lines that could not be parsed
+// carry the reason in Err and are reported as failures without being sent.
+type batchLine struct {
+	Line   int
+	Record Record
+	Err    error
+}
+
+// readBatchFile parses a file of "domain ip" pairs, one per line. With
+// --delete the address may be left out to remove every record of the domain.
+// Blank lines and # comments are ignored. A path of "-" reads standard
+// input.
+func readBatchFile(path string) ([]batchLine, error) {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+	} else {
+		path = "standard input"
+	}
+
+	var lines []batchLine
+	scanner := bufio.NewScanner(file)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		line := batchLine{Line: n}
+		switch {
+		case len(fields) == 2:
+			line.Record, line.Err = batchRecord(fields[0], fields[1])
+		case len(fields) == 1 && *cmdDelete:
+			line.Record, line.Err = batchRecord(fields[0], "")
+		case *cmdDelete:
+			line.Err = fmt.Errorf("expected \"<domain> [ip]\"")
+		default:
+			line.Err = fmt.Errorf("expected \"<domain> <ip>\"")
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return lines, nil
+}
+
+// batchRecord applies the same checks and normalization as --domain and --ip
+// on the command line.
+func batchRecord(domain, ip string) (Record, error) {
+	domain = qualifyDomain(domain)
+	if err := validateDomain(domain); err != nil {
+		return Record{}, err
+	}
+	if err := checkAllowedDomain(domain); err != nil {
+		return Record{}, err
+	}
+	ip, err := canonicalIP("ip", ip)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Domain: domain, IP: ip}, nil
+}
+
+// sendRecord sends a single record change and reports only whether it
+// succeeded, leaving the output to the caller.
+func sendRecord(ctx context.Context, method string, r Record) error {
+	resp, body, err := apiRequest(ctx, method, "/dns", r)
+	if err != nil {
+		return err
+	}
+	return statusError(resp, body)
+}
+
+// runBatch adds or deletes every record listed in path, continuing past
+// failures. Up to --concurrency requests are in flight at once; results are
+// still printed in file order, followed by a summary. An interrupt cancels
+// the requests that have not completed.
+func runBatch(path string) error {
+	start := time.Now()
+	lines, err := readBatchFile(path)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		if *optSummaryJSON {
+			return printBatchSummary(batchSummary{})
+		}
+		return nil
+	}
+
+	method, verb := "POST", "added"
+	if *cmdDelete {
+		method, verb = "DELETE", "deleted"
+	}
+	if *optDryRun {
+		verb = "not sent (dry run)"
+	} else if !*cmdDelete {
+		valid := 0
+		for _, l := range lines {
+			if l.Err == nil {
+				valid++
+			}
+		}
+		warnQuota(valid)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]error, len(lines))
+	skipped := make([]bool, len(lines))
+	done := make([]chan struct{}, len(lines))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// --rate spaces out the start of requests from this single dispatch
+	// loop, so the cap holds however many of them run concurrently.
+	var tick <-chan time.Time
+	if *optRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *optRate))
+		defer ticker.Stop()
+		tick = ticker.C
+		if *flagVerbose {
+			fmt.Fprintf(os.Stderr, "* rate limited to %g requests per second, %d in flight at most\n", *optRate, *optConcurrency)
+		}
+	}
+
+	var wg sync.WaitGroup
+	stats := startProgress(len(lines), *optStatsInterval)
+	dispatch := func() {
+		sem := make(chan struct{}, *optConcurrency)
+		started := 0
+		for i, l := range lines {
+			if l.Err != nil || ctx.Err() != nil {
+				results[i] = l.Err
+				if results[i] == nil {
+					results[i] = ctx.Err()
+					skipped[i] = true
+				}
+				stats.increment()
+				close(done[i])
+				continue
+			}
+
+			if tick != nil && started > 0 {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+				}
+			}
+			started++
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int, r Record) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i] = sendRecord(ctx, method, r)
+				stats.increment()
+				close(done[i])
+			}(i, l.Record)
+		}
+	}
+	if *optDryRun {
+		// Dry runs print each request as it is built, so they are
+		// finished before any results to keep the output in file order.
+		*optConcurrency = 1
+		dispatch()
+		wg.Wait()
+	} else {
+		go dispatch()
+	}
+
+	summary := batchSummary{Total: len(lines)}
+	for i, l := range lines {
+		<-done[i]
+		if skipped[i] {
+			fmt.Printf("line %d: skipped\n", l.Line)
+			summary.Skipped++
+			continue
+		}
+		if err := results[i]; err != nil {
+			fmt.Printf("line %d: failed: %v\n", l.Line, err)
+			summary.Failed++
+			if *optAbortOnError && ctx.Err() == nil {
+				cancel()
+				fmt.Printf("Aborting after first failure, cancelling outstanding requests\n")
+			}
+			continue
+		}
+		if l.Record.IP == "" {
+			fmt.Printf("line %d: %s: %s\n", l.Line, l.Record.Domain, verb)
+		} else {
+			fmt.Printf("line %d: %s -> %s: %s\n", l.Line, l.Record.Domain, l.Record.IP, verb)
+		}
+		summary.Succeeded++
+	}
+	wg.Wait()
+	stats.finish()
+
+	summary.DurationMS = time.Since(start).Milliseconds()
+	if *optSummaryJSON {
+		if err := printBatchSummary(summary); err != nil {
+			return err
+		}
+	} else if summary.Skipped > 0 {
+		fmt.Printf("\nBatch: %d succeeded, %d failed, %d skipped\n", summary.Succeeded, summary.Failed, summary.Skipped)
+	} else {
+		fmt.Printf("\nBatch: %d succeeded, %d failed\n", summary.Succeeded, summary.Failed)
+	}
+
+	if summary.Skipped > 0 {
+		return fmt.Errorf("%d of %d batch lines failed, %d skipped", summary.Failed, len(lines), summary.Skipped)
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d batch lines failed", summary.Failed, len(lines))
+	}
+	return nil
+}
+
+// batchSummary is the --summary-json report of a batch run. Skipped counts
+// lines never sent because the run was aborted or interrupted.
+type batchSummary struct {
+	Total      int   `json:"total"`
+	Succeeded  int   `json:"succeeded"`
+	Failed     int   `json:"failed"`
+	Skipped    int   `json:"skipped"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+func printBatchSummary(summary batchSummary) error {
+	out := os.Stdout
+	if summaryOut != nil {
+		out = summaryOut
+	}
+	return json.NewEncoder(out).Encode(summary)
+}
+
+// readStdinRecords decodes a single JSON record or an array of records from
+// standard input.
+func readStdinRecords() ([]Record, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read standard input: %v", err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no records on standard input")
+	}
+
+	var records []Record
+	if data[0] == '[' {
+		err = json.Unmarshal(data, &records)
+	} else {
+		var r Record
+		err = json.Unmarshal(data, &r)
+		records = []Record{r}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON on standard input: %v", err)
+	}
+	return records, nil
+}
+
+// stdinRecord applies the checks of the command-line flags to a record read
+// by --stdin. Updates need new_ip; ip then optionally selects the address to
+// replace.
+func stdinRecord(r Record) (Record, error) {
+	r.Domain = qualifyDomain(r.Domain)
+	if err := validateDomain(r.Domain); err != nil {
+		return Record{}, err
+	}
+	if err := checkAllowedDomain(r.Domain); err != nil {
+		return Record{}, err
+	}
+
+	r.Type = strings.ToUpper(r.Type)
+	if r.Type == "PTR" {
+		return Record{}, fmt.Errorf("PTR records are not supported with --stdin")
+	}
+	var err error
+	if r.IP, err = recordValue("ip", r.Type, r.IP); err != nil {
+		return Record{}, err
+	}
+	if r.NewIP, err = recordValue("new_ip", r.Type, r.NewIP); err != nil {
+		return Record{}, err
+	}
+	switch {
+	case *cmdAdd && r.IP == "":
+		return Record{}, fmt.Errorf("%s: ip is required", r.Domain)
+	case *cmdUpdate && r.NewIP == "":
+		return Record{}, fmt.Errorf("%s: new_ip is required", r.Domain)
+	}
+	return r, nil
+}
+
+// runStdinRecords sends each record read by --stdin as an add or update.
+// Every record is checked before the first one is sent, so a malformed
+// entry leaves the server untouched.
+func runStdinRecords() error {
+	records, err := readStdinRecords()
+	if err != nil {
+		return err
+	}
+	for i, r := range records {
+		if records[i], err = stdinRecord(r); err != nil {
+			return fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+
+	method := "POST"
+	if *cmdUpdate {
+		method = "PUT"
+	} else {
+		warnQuota(len(records))
+	}
+
+	failed := 0
+	for n, r := range records {
+		if err := makeRequest(method, "/dns", r); err != nil {
+			printError(fmt.Errorf("%s: %w", r.Domain, err))
+			failed++
+			if *optAbortOnError {
+				return fmt.Errorf("aborted after %d of %d records", n, len(records))
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed", failed, len(records))
+	}
+	return nil
+}
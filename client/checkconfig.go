@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// minAPIKeyLength is the shortest API key --check-config accepts as
+// plausible. The server's default key is 32 hex characters.
+const minAPIKeyLength = 16
+
+// checkConfig validates the configuration without contacting the server,
+// printing one line per check.
+func checkConfig() error {
+	failed, total := 0, 0
+	check := func(name string, err error) {
+		total++
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", name, err)
+			failed++
+			return
+		}
+		fmt.Printf("✓ %s\n", name)
+	}
+
+	var cf configFile
+	path := configPath()
+	info, statErr := os.Stat(path)
+	switch {
+	case os.IsNotExist(statErr):
+		fmt.Printf("- config file %s not found, using the environment\n", path)
+	case statErr != nil:
+		check("config file readable", statErr)
+	default:
+		var err error
+		cf, err = readConfigFile()
+		check("config file parses", err)
+		if runtime.GOOS != "windows" {
+			var permErr error
+			if perm := info.Mode().Perm(); perm&0077 != 0 {
+				permErr = fmt.Errorf("mode is %04o, expected 0600 (chmod 600 %s)", perm, path)
+			}
+			check("config file permissions", permErr)
+		}
+	}
+
+	cfg, err := loadConfig()
+	check(fmt.Sprintf("profile %q loads", profileName(cf)), err)
+	if err != nil {
+		return &configErr{fmt.Errorf("%d of %d checks failed", failed, total)}
+	}
+
+	check("server URL", checkServerURL(cfg.Server))
+	check("API key", checkAPIKey(cfg.APIKey))
+
+	if failed > 0 {
+		return &configErr{fmt.Errorf("%d of %d checks failed", failed, total)}
+	}
+	return nil
+}
+
+func checkServerURL(server string) error {
+	if server == "" {
+		return fmt.Errorf("not set")
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("%q does not parse: %v", server, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must start with http:// or https://", server)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", server)
+	}
+	if u.Path != "" {
+		return fmt.Errorf("%q should not include a path or trailing slash", server)
+	}
+	return nil
+}
+
+func checkAPIKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("not set")
+	case strings.TrimSpace(key) != key || strings.ContainsAny(key, " \t\r\n"):
+		return fmt.Errorf("contains whitespace")
+	case len(key) < minAPIKeyLength:
+		return fmt.Errorf("only %d characters, expected at least %d", len(key), minAPIKeyLength)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+)
+
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorDim   = "\033[2m"
+)
+
+// colorEnabled reports whether output written to f should be colored,
+// following --color and, in auto mode, the NO_COLOR convention and whether
+// f is a terminal.
+func colorEnabled(f *os.File) bool {
+	switch *optColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// colorize wraps s in the given color when stdout is colored.
+func colorize(color, s string) string {
+	if !colorEnabled(os.Stdout) {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// completionTimeout bounds the record lookup of --complete-domains, so a
+// slow or unreachable server never hangs the shell.
+const completionTimeout = 2 * time.Second
+
+// hiddenFlags are internal to the completion scripts and neither documented
+// nor offered as completions.
+var hiddenFlags = []string{"complete-domains"}
+
+// completionFlags returns every registered flag name with the leading "--",
+// split into flags that take a value and boolean switches.
+func completionFlags() (valued, switches []string) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if contains(hiddenFlags, f.Name) {
+			return
+		}
+		name := "--" + f.Name
+		if len(f.Name) == 1 {
+			name = "-" + f.Name
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			switches = append(switches, name)
+		} else {
+			valued = append(valued, name)
+		}
+	})
+	sort.Strings(valued)
+	sort.Strings(switches)
+	return valued, switches
+}
+
+// printCompletion writes a completion script for shell to stdout. Values of
+// --domain are completed from the records on the configured server through
+// --complete-domains.
+func printCompletion(shell string) error {
+	valued, switches := completionFlags()
+	all := append(append([]string{}, valued...), switches...)
+	sort.Strings(all)
+
+	switch shell {
+	case "bash":
+		fmt.Printf(`# dnscli bash completion; load with: source <(dnscli --completion bash)
+_dnscli() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+        --domain)
+            COMPREPLY=($(dnscli --complete-domains "$cur" 2>/dev/null))
+            return ;;
+        %s)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return ;;
+    esac
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+}
+complete -F _dnscli dnscli
+`, strings.Join(without(valued, "--domain"), "|"), strings.Join(all, " "))
+
+	case "zsh":
+		fmt.Printf(`#compdef dnscli
+# dnscli zsh completion; load with: source <(dnscli --completion zsh)
+_dnscli() {
+    if [[ ${words[CURRENT-1]} == --domain ]]; then
+        compadd -- ${(f)"$(dnscli --complete-domains "$PREFIX" 2>/dev/null)"}
+    elif [[ " %s " == *" ${words[CURRENT-1]} "* ]]; then
+        _files
+    else
+        compadd -- %s
+    fi
+}
+compdef _dnscli dnscli
+`, strings.Join(without(valued, "--domain"), " "), strings.Join(all, " "))
+
+	case "fish":
+		fmt.Println("# dnscli fish completion; load with: dnscli --completion fish | source")
+		fmt.Println("complete -c dnscli -f")
+		for _, name := range all {
+			opt := "-l " + strings.TrimPrefix(name, "--")
+			if !strings.HasPrefix(name, "--") {
+				opt = "-s " + strings.TrimPrefix(name, "-")
+			}
+			switch {
+			case name == "--domain":
+				fmt.Printf("complete -c dnscli %s -x -a '(dnscli --complete-domains (commandline -ct) 2>/dev/null)'\n", opt)
+			case contains(valued, name):
+				fmt.Printf("complete -c dnscli %s -r -F\n", opt)
+			default:
+				fmt.Printf("complete -c dnscli %s\n", opt)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+// completeDomains prints the domains on the configured server that start
+// with prefix, one per line. It is called by the completion scripts on every
+// tab press, so any failure, including a missing config or an unreachable
+// server, prints nothing instead of an error.
+func completeDomains(prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	resp, body, err := apiRequest(ctx, "GET", "/dns", nil)
+	if err != nil || statusError(resp, body) != nil {
+		return
+	}
+	var list APIResponse
+	if json.Unmarshal(body, &list) != nil {
+		return
+	}
+
+	prefix = strings.ToLower(prefix)
+	seen := make(map[string]bool)
+	var domains []string
+	for _, r := range list.Records {
+		if strings.HasPrefix(strings.ToLower(r.Domain), prefix) && !seen[r.Domain] {
+			seen[r.Domain] = true
+			domains = append(domains, r.Domain)
+		}
+	}
+	sort.Strings(domains)
+	for _, d := range domains {
+		fmt.Println(d)
+	}
+}
+
+// flagGiven reports whether the named flag was set on the command line,
+// which for string flags is the only way to tell an empty value from none.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+func without(list []string, s string) []string {
+	var kept []string
+	for _, v := range list {
+		if v != s {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// loadOtherProfile loads the profile named by --other-profile. Unlike
+// loadConfig it ignores --server, --apikey and the environment, which only
+// describe the primary server.
+func loadOtherProfile(name string) (Config, error) {
+	cf, err := readConfigFile()
+	if err != nil {
+		return Config{}, err
+	}
+	cfg, ok := cf.Profiles[name]
+	if !ok {
+		return Config{}, fmt.Errorf("profile %q not found", name)
+	}
+	if cfg.APIKeyFile != "" {
+		if cfg.APIKey, err = readAPIKeyFile(cfg.APIKeyFile); err != nil {
+			return Config{}, err
+		}
+	}
+	return cfg, nil
+}
+
+// addressesByDomain maps each domain to its sorted addresses.
+func addressesByDomain(records []Record) map[string][]string {
+	m := make(map[string][]string)
+	for _, r := range records {
+		m[r.Domain] = append(m[r.Domain], r.IP)
+	}
+	for _, ips := range m {
+		sort.Strings(ips)
+	}
+	return m
+}
+
+// runDiff compares the records of the selected server with those of the
+// --other-profile server and reports domains only on one side or mapped to
+// different addresses. Any difference makes it fail.
+func runDiff(other string) error {
+	cfgA, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	cfgB, err := loadOtherProfile(other)
+	if err != nil {
+		return configError(err)
+	}
+
+	recordsA, err := fetchRecords(cfgA)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records from %s: %w", cfgA.Server, err)
+	}
+	recordsB, err := fetchRecords(cfgB)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records from %s: %w", cfgB.Server, err)
+	}
+
+	a, b := addressesByDomain(recordsA), addressesByDomain(recordsB)
+	var onlyA, onlyB, changed []string
+	for d, ipsA := range a {
+		ipsB, ok := b[d]
+		switch {
+		case !ok:
+			onlyA = append(onlyA, d)
+		case strings.Join(ipsA, " ") != strings.Join(ipsB, " "):
+			changed = append(changed, d)
+		}
+	}
+	for d := range b {
+		if _, ok := a[d]; !ok {
+			onlyB = append(onlyB, d)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	sort.Strings(changed)
+
+	labelA, labelB := cfgA.Server, fmt.Sprintf("%s (profile %s)", cfgB.Server, other)
+	if len(onlyA)+len(onlyB)+len(changed) == 0 {
+		fmt.Printf("No differences: %s and %s have the same %d records\n", labelA, labelB, len(recordsA))
+		return nil
+	}
+
+	fmt.Printf("Only on %s:\n", labelA)
+	for _, d := range onlyA {
+		fmt.Printf("  %s -> %s\n", d, strings.Join(a[d], ", "))
+	}
+	if len(onlyA) == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Printf("\nOnly on %s:\n", labelB)
+	for _, d := range onlyB {
+		fmt.Printf("  %s -> %s\n", d, strings.Join(b[d], ", "))
+	}
+	if len(onlyB) == 0 {
+		fmt.Println("  (none)")
+	}
+	fmt.Printf("\nDifferent addresses:\n")
+	for _, d := range changed {
+		fmt.Printf("  %s: %s | %s\n", d, strings.Join(a[d], ", "), strings.Join(b[d], ", "))
+	}
+	if len(changed) == 0 {
+		fmt.Println("  (none)")
+	}
+
+	return fmt.Errorf("servers differ: %d only on %s, %d only on %s, %d different",
+		len(onlyA), cfgA.Server, len(onlyB), cfgB.Server, len(changed))
+}
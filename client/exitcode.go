@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Exit codes let scripts tell apart why dnscli failed. They are part of the
+// command-line interface and documented in showUsage.
+const (
+	exitFailure = 1 // any other error
+	exitUsage   = 2 // invalid flags or arguments
+	exitConfig  = 3 // configuration missing or unusable
+	exitNetwork = 4 // server could not be reached
+	exitServer  = 5 // server answered with an error status
+)
+
+// configErr marks a failure to load the configuration.
+type configErr struct {
+	err error
+}
+
+func (e *configErr) Error() string { return e.err.Error() }
+func (e *configErr) Unwrap() error { return e.err }
+
+// networkError marks a request that got no response from the server.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+func (e *networkError) Unwrap() error { return e.err }
+
+// serverError is a non-2xx response from the server.
+type serverError struct {
+	Code     int
+	Status   string
+	Body     string
+	Endpoint string
+}
+
+func (e *serverError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("server returned %s", e.Status)
+	}
+	return fmt.Sprintf("server returned %s: %s", e.Status, e.Body)
+}
+
+// jsonError is the form in which errors are printed with --output json.
+// Code is the HTTP status of a server error and Endpoint the API path of
+// the failed request; both are left out when they do not apply.
+type jsonError struct {
+	Error    string `json:"error"`
+	Code     int    `json:"code,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+func newJSONError(err error) jsonError {
+	je := jsonError{Error: err.Error()}
+	var srvErr *serverError
+	var urlErr *url.Error
+	if errors.As(err, &srvErr) {
+		je.Code = srvErr.Code
+		je.Endpoint = srvErr.Endpoint
+	} else if errors.As(err, &urlErr) {
+		if u, err := url.Parse(urlErr.URL); err == nil {
+			je.Endpoint = u.Path
+		}
+	}
+	return je
+}
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	var cfgErr *configErr
+	var netErr *networkError
+	var srvErr *serverError
+	switch {
+	case errors.As(err, &cfgErr):
+		return exitConfig
+	case errors.As(err, &netErr):
+		return exitNetwork
+	case errors.As(err, &srvErr):
+		return exitServer
+	}
+	return exitFailure
+}
+
+// isNotFound reports whether err is a 404 response from the server.
+func isNotFound(err error) bool {
+	var srvErr *serverError
+	return errors.As(err, &srvErr) && srvErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// recordGroup is the grouped export form of all addresses of one domain.
+type recordGroup struct {
+	Domain string   `json:"domain"`
+	IPs    []string `json:"ips"`
+}
+
+// groupRecords collects the addresses of each domain, keeping the order in
+// which domains first appear.
+func groupRecords(records []Record) []recordGroup {
+	groups := []recordGroup{}
+	index := make(map[string]int)
+	for _, r := range records {
+		if i, ok := index[r.Domain]; ok {
+			groups[i].IPs = append(groups[i].IPs, r.IP)
+			continue
+		}
+		index[r.Domain] = len(groups)
+		groups = append(groups, recordGroup{Domain: r.Domain, IPs: []string{r.IP}})
+	}
+	return groups
+}
+
+// sortRecords orders records by domain, then IP, so that JSON output is
+// stable across runs and diffs cleanly under version control.
+func sortRecords(records []Record) []Record {
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Domain != sorted[j].Domain {
+			return sorted[i].Domain < sorted[j].Domain
+		}
+		return sorted[i].IP < sorted[j].IP
+	})
+	return sorted
+}
+
+func writeJSONExport(w io.Writer, records []Record) error {
+	records = sortRecords(records)
+	var v interface{} = groupRecords(records)
+	if *optFlatten {
+		v = records
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeCSVRecords writes one domain,ip row per record after a header row.
+func writeCSVRecords(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"domain", "ip"})
+	for _, r := range records {
+		cw.Write([]string{r.Domain, r.IP})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCSVExport(w io.Writer, records []Record) error {
+	if *optFlatten {
+		return writeCSVRecords(w, records)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"domain", "ips"})
+	for _, g := range groupRecords(records) {
+		cw.Write([]string{g.Domain, strings.Join(g.IPs, " ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeBatchExport writes "domain ip" lines in the format read by --batch.
+func writeBatchExport(w io.Writer, records []Record) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range sortRecords(records) {
+		fmt.Fprintf(bw, "%s %s\n", r.Domain, r.IP)
+	}
+	return bw.Flush()
+}
+
+func writeExportFile(path string, records []Record, write func(io.Writer, []Record) error) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return write(out, records)
+}
+
+// writeHostsExport brings path up to date in hosts format. When path already
+// exists its comments, blank lines and ordering are preserved and only the
+// record lines change.
+func writeHostsExport(path string, records []Record) error {
+	var lines []hostsLine
+	if file, err := os.Open(path); err == nil {
+		lines, err = parseHosts(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("cannot merge into existing file: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return writeHosts(out, mergeHosts(lines, records))
+}
+
+// runExport writes the server's records to path. The format follows the
+// file extension: .json, .csv and .txt (the --batch format), anything else is
+// written as a hosts file.
+func runExport(path string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = writeExportFile(path, records, writeJSONExport)
+	case ".csv":
+		err = writeExportFile(path, records, writeCSVExport)
+	case ".txt":
+		err = writeExportFile(path, records, writeBatchExport)
+	default:
+		err = writeHostsExport(path, records)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	fmt.Printf("Exported %d records to %s\n", len(records), path)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// defaultServerPort is the port dnsmassq-api-driver.py listens on.
+const defaultServerPort = "18081"
+
+// defaultGateway returns the IPv4 default gateway from the OS routing table.
+func defaultGateway() (net.IP, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxGateway()
+	case "darwin", "freebsd", "openbsd", "netbsd":
+		return bsdGateway()
+	}
+	return nil, fmt.Errorf("gateway detection is not supported on %s", runtime.GOOS)
+}
+
+func linuxGateway() (net.IP, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		// The kernel prints addresses in host (little-endian) byte order.
+		return net.IPv4(raw[3], raw[2], raw[1], raw[0]), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no default route found")
+}
+
+func bsdGateway() (net.IP, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && key == "gateway" {
+			if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no default route found")
+}
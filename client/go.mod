@@ -0,0 +1,3 @@
+module github.com/vourteen14/openwrt-dnsmassq-api/client
+
+go 1.19
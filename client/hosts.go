@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// hostsLine is one line of a hosts-format file. Record lines carry an
+// address and one or more names; every other line (comments, blank lines)
+// is kept verbatim in Text so files survive an export/edit/import cycle.
+type hostsLine struct {
+	IP      string
+	Names   []string
+	Comment string
+	Text    string
+}
+
+func (l hostsLine) isRecord() bool {
+	return l.IP != ""
+}
+
+func parseHosts(r io.Reader) ([]hostsLine, error) {
+	var lines []hostsLine
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := scanner.Text()
+
+		content, comment := text, ""
+		if i := strings.Index(text, "#"); i >= 0 {
+			content, comment = text[:i], strings.TrimSpace(text[i+1:])
+		}
+
+		fields := strings.Fields(content)
+		if len(fields) == 0 {
+			lines = append(lines, hostsLine{Text: text})
+			continue
+		}
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("line %d: expected \"<ip> <name>...\"", n)
+		}
+		lines = append(lines, hostsLine{IP: fields[0], Names: fields[1:], Comment: comment})
+	}
+	return lines, scanner.Err()
+}
+
+func hostsRecords(lines []hostsLine) []Record {
+	var records []Record
+	for _, l := range lines {
+		for _, name := range l.Names {
+			records = append(records, Record{Domain: name, IP: l.IP})
+		}
+	}
+	return records
+}
+
+// mergeHosts rewrites the record lines of an existing file to match records,
+// keeping comments, blank lines and ordering. Names no longer present are
+// dropped and records not yet in the file are added, grouped by address.
+func mergeHosts(lines []hostsLine, records []Record) []hostsLine {
+	present := make(map[Record]bool)
+	for _, r := range records {
+		present[Record{Domain: r.Domain, IP: r.IP}] = true
+	}
+
+	written := make(map[Record]bool)
+	var merged []hostsLine
+	for _, l := range lines {
+		if !l.isRecord() {
+			merged = append(merged, l)
+			continue
+		}
+
+		var names []string
+		for _, name := range l.Names {
+			key := Record{Domain: name, IP: l.IP}
+			if present[key] && !written[key] {
+				names = append(names, name)
+				written[key] = true
+			}
+		}
+		if len(names) > 0 {
+			l.Names = names
+			merged = append(merged, l)
+		}
+	}
+
+	var added []hostsLine
+	index := make(map[string]int)
+	for _, r := range records {
+		key := Record{Domain: r.Domain, IP: r.IP}
+		if written[key] {
+			continue
+		}
+		written[key] = true
+
+		if i, ok := index[r.IP]; ok {
+			added[i].Names = append(added[i].Names, r.Domain)
+			continue
+		}
+		index[r.IP] = len(added)
+		added = append(added, hostsLine{IP: r.IP, Names: []string{r.Domain}})
+	}
+
+	// New records go after the last existing record so that trailing
+	// comments and blank lines stay at the end of the file.
+	at := len(merged)
+	for i := len(merged) - 1; i >= 0; i-- {
+		if merged[i].isRecord() {
+			at = i + 1
+			break
+		}
+	}
+	return append(merged[:at], append(added, merged[at:]...)...)
+}
+
+func writeHosts(w io.Writer, lines []hostsLine) error {
+	bw := bufio.NewWriter(w)
+	for _, l := range lines {
+		if !l.isRecord() {
+			fmt.Fprintln(bw, l.Text)
+			continue
+		}
+		line := l.IP + "\t" + strings.Join(l.Names, " ")
+		if l.Comment != "" {
+			line += "\t# " + l.Comment
+		}
+		fmt.Fprintln(bw, line)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importPlan is the result of comparing an import file against the records
+// currently on the server. Its JSON form is consumed by CI jobs, so the field
+// names and the always-present (possibly empty) arrays are part of the
+// interface.
+type importPlan struct {
+	Add      []Record `json:"add"`
+	Skip     []Record `json:"skip"`
+	Conflict []Record `json:"conflict"`
+}
+
+func readImportFile(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		lines, err := readBatchFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lines {
+			if l.Err != nil {
+				return nil, fmt.Errorf("%s: line %d: %v", path, l.Line, l.Err)
+			}
+			records = append(records, l.Record)
+		}
+	} else if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		// Accept both the flat form and the grouped form written by
+		// --export without --flatten.
+		var entries []struct {
+			Domain string   `json:"domain"`
+			IP     string   `json:"ip"`
+			IPs    []string `json:"ips"`
+		}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		for _, e := range entries {
+			if e.IP != "" || len(e.IPs) == 0 {
+				records = append(records, Record{Domain: e.Domain, IP: e.IP})
+			}
+			for _, ip := range e.IPs {
+				records = append(records, Record{Domain: e.Domain, IP: ip})
+			}
+		}
+	} else {
+		lines, err := parseHosts(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		records = hostsRecords(lines)
+	}
+
+	if *optTransform != "" {
+		transformed, err := transformRecords(*optTransform, records)
+		if err != nil {
+			return nil, err
+		}
+		if !*flagQuiet {
+			fmt.Fprintf(os.Stderr, "Transform kept %d of %d records\n", len(transformed), len(records))
+		}
+		records = transformed
+	}
+
+	normalized := 0
+	for i := range records {
+		if *optNormal {
+			if r := normalizeRecord(records[i]); r != records[i] {
+				records[i] = r
+				normalized++
+			}
+		} else {
+			records[i].Domain = appendSuffix(records[i].Domain)
+		}
+		if records[i].Domain == "" || records[i].IP == "" {
+			return nil, fmt.Errorf("%s: record %d requires domain and ip", path, i+1)
+		}
+		// Records are checked in their canonical form but, without
+		// --normalize, sent as written.
+		if err := validateDomain(normalizeDomain(records[i].Domain)); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, i+1, err)
+		}
+		if _, err := canonicalIP("ip", records[i].IP); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, i+1, err)
+		}
+		if err := checkAllowedDomain(records[i].Domain); err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", path, i+1, err)
+		}
+	}
+
+	if *optNormal && !*flagQuiet {
+		fmt.Fprintf(os.Stderr, "Normalized %d of %d records\n", normalized, len(records))
+	}
+	if *optDedupe {
+		var removed int
+		records, removed = dedupeRecords(records)
+		if !*flagQuiet {
+			fmt.Fprintf(os.Stderr, "Removed %d duplicate records\n", removed)
+		}
+	}
+	return records, nil
+}
+
+// dedupeRecords drops repeated identical domain/IP pairs, keeping the first
+// occurrence. The same domain with different addresses is left for the
+// conflict handling in planImport.
+func dedupeRecords(records []Record) ([]Record, int) {
+	seen := make(map[Record]bool)
+	var unique []Record
+	for _, r := range records {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		unique = append(unique, r)
+	}
+	return unique, len(records) - len(unique)
+}
+
+// planImport sorts each record into add, skip (already present) or conflict
+// (domain mapped to another address). Conflicts are added anyway when
+// --merge-ips is set.
+func planImport(records, existing []Record) importPlan {
+	plan := importPlan{
+		Add:      []Record{},
+		Skip:     []Record{},
+		Conflict: []Record{},
+	}
+
+	for _, r := range records {
+		exists, conflict := false, false
+		for _, e := range existing {
+			if e.Domain != r.Domain {
+				continue
+			}
+			if e.IP == r.IP {
+				exists = true
+				break
+			}
+			conflict = true
+		}
+
+		switch {
+		case exists:
+			plan.Skip = append(plan.Skip, r)
+		case conflict && !*optMerge:
+			plan.Conflict = append(plan.Conflict, r)
+		default:
+			plan.Add = append(plan.Add, r)
+			existing = append(existing, r)
+		}
+	}
+	return plan
+}
+
+func printPlan(plan importPlan) error {
+	if *optOutput == "json" {
+		plan.Add = sortRecords(plan.Add)
+		plan.Skip = sortRecords(plan.Skip)
+		plan.Conflict = sortRecords(plan.Conflict)
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	for _, r := range plan.Add {
+		fmt.Printf("+ %s -> %s\n", r.Domain, r.IP)
+	}
+	for _, r := range plan.Skip {
+		fmt.Printf("= %s -> %s (exists)\n", r.Domain, r.IP)
+	}
+	for _, r := range plan.Conflict {
+		fmt.Printf("! %s -> %s (domain has another address)\n", r.Domain, r.IP)
+	}
+	fmt.Printf("\nPlan: %d to add, %d skipped, %d conflicts\n", len(plan.Add), len(plan.Skip), len(plan.Conflict))
+	return nil
+}
+
+// writePlanDir stores each category of the plan in its own file inside dir
+// (add.json, skip.json, conflict.json) so CI can archive them as artifacts.
+func writePlanDir(dir string, plan importPlan) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	files := map[string][]Record{
+		"add.json":      sortRecords(plan.Add),
+		"skip.json":     sortRecords(plan.Skip),
+		"conflict.json": sortRecords(plan.Conflict),
+	}
+	for name, records := range files {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importRecord adds r and reports whether the server already had it, which
+// happens when the record was created after the plan was made.
+func importRecord(r Record) (bool, error) {
+	resp, body, err := apiRequest(context.Background(), "POST", "/dns", Record{Domain: r.Domain, IP: r.IP})
+	if err != nil {
+		return false, err
+	}
+	if err := statusError(resp, body); err != nil {
+		return false, err
+	}
+	var result APIResponse
+	if json.Unmarshal(body, &result) == nil && result.Status == "exists" {
+		return true, nil
+	}
+	return false, nil
+}
+
+func runImport(path string) error {
+	records, err := readImportFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	existing, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch existing records: %w", err)
+	}
+
+	plan := planImport(records, existing)
+	if *optDryRun {
+		if *optPlanDir != "" {
+			if err := writePlanDir(*optPlanDir, plan); err != nil {
+				return fmt.Errorf("failed to write plan: %v", err)
+			}
+		}
+		return printPlan(plan)
+	}
+
+	warnQuota(len(plan.Add))
+
+	failed, added, skipped := 0, 0, len(plan.Skip)
+	stats := startProgress(len(plan.Add), *optStatsInterval)
+	for _, r := range plan.Add {
+		exists, err := importRecord(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %s: %v\n", r.Domain, err)
+			failed++
+			if *optAbortOnError {
+				stats.finish()
+				fmt.Printf("\nAborted after first failure: %d of %d records imported\n", added, len(plan.Add))
+				return fmt.Errorf("import aborted at %s", r.Domain)
+			}
+		} else if exists {
+			fmt.Printf("= %s -> %s (exists)\n", r.Domain, r.IP)
+			skipped++
+		} else {
+			fmt.Printf("+ %s -> %s\n", r.Domain, r.IP)
+			added++
+		}
+		stats.increment()
+	}
+	stats.finish()
+	for _, r := range plan.Conflict {
+		fmt.Fprintf(os.Stderr, "dnscli: %s: domain has another address, skipped (use --merge-ips)\n", r.Domain)
+	}
+
+	fmt.Printf("\nImported %d records, %d skipped, %d conflicts, %d failed\n",
+		added, skipped, len(plan.Conflict), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d records failed to import", failed)
+	}
+	return nil
+}
@@ -1,33 +1,136 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"text/template"
 	"time"
 )
 
 const (
 	version = "1.0.0"
 	userAgent = "dnscli/" + version
+	
+	// maxClockSkew is the largest difference between the server's Date
+	// header and local time tolerated without a warning.
+	maxClockSkew = 2 * time.Minute
 )
 
+var skewWarning sync.Once
+
+// apiKeyFileWarning reports a profile with both apikey and apikey_file once.
+var apiKeyFileWarning sync.Once
+
+// staleAfter is the parsed value of --stale-after.
+var staleAfter time.Duration
+
+// minTLSVersion is the parsed value of --min-tls; zero keeps Go's default.
+var minTLSVersion uint16
+
+// proxyURL is the parsed value of --proxy; nil falls back to the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+var proxyURL *url.URL
+
+// sessionConfig, when set, is returned by loadConfig instead of reading the
+// configuration again; --interactive loads it once per session.
+var sessionConfig *Config
+
+// summaryOut, when set, receives the --summary-json object instead of
+// stdout; --quiet keeps the real stdout here so the summary survives it.
+var summaryOut *os.File
+
+// recordTemplate is the parsed value of --format-template.
+var recordTemplate *template.Template
+
+// caPool holds the certificates loaded with --cacert; nil uses the system
+// roots.
+var caPool *x509.CertPool
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
 type Config struct {
-	Server string `json:"server"`
-	APIKey string `json:"apikey"`
+	Server       string `json:"server"`
+	APIKey       string `json:"apikey"`
+	DomainSuffix string `json:"domain_suffix,omitempty"`
+	
+	// APIKeyFile names a file holding the API key, read on every run so
+	// the secret can live in a secrets manager mount. It takes precedence
+	// over APIKey.
+	APIKeyFile string `json:"apikey_file,omitempty"`
+	
+	// PreviousAPIKey is kept after --rotate-key as a fallback until the
+	// rotation is confirmed.
+	PreviousAPIKey string `json:"previous_apikey,omitempty"`
+	
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	
+	// CertPin is the SHA-256 fingerprint of the server certificate. When
+	// set, only that certificate is accepted.
+	CertPin string `json:"cert_pin,omitempty"`
+	
+	// UserAgent replaces "dnscli" in the User-Agent header for gateways
+	// that filter or log by it; the client version is still appended.
+	UserAgent string `json:"user_agent,omitempty"`
+	
+	// BasicAuth is "user:password" for a reverse proxy that requires HTTP
+	// basic authentication in front of the API. It is sent in addition to
+	// the key, so it cannot be combined with the bearer AuthMode.
+	BasicAuth string `json:"basic_auth,omitempty"`
+	
+	// AuthMode selects how the key is sent: "apikey" (the default) uses the
+	// X-API-Key header, "bearer" an Authorization: Bearer header for
+	// reverse proxies that expect one.
+	AuthMode string `json:"auth_mode,omitempty"`
 }
 
 type Record struct {
-	Domain string `json:"domain"`
-	IP     string `json:"ip,omitempty"`
-	NewIP  string `json:"new_ip,omitempty"`
+	Domain  string `json:"domain"`
+	IP      string `json:"ip,omitempty"`
+	NewIP   string `json:"new_ip,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	Created string `json:"created,omitempty"`
+	Updated string `json:"updated,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+	
+	// CurrentIP, sent with updates, asks the server to refuse the change
+	// unless the domain still resolves to exactly this address.
+	CurrentIP string `json:"current_ip,omitempty"`
+	
+	// Type is the record type. Address records may leave it empty, in
+	// which case it is A or AAAA depending on IP; for CNAME and TXT records
+	// IP holds the target name or the text. PTR records are named by the
+	// reverse lookup name (in-addr.arpa or ip6.arpa) and IP holds the host.
+	Type string `json:"type,omitempty"`
+	
+	// Reason and Client are audit metadata recorded by servers that track
+	// who changed a record and why.
+	Reason string `json:"reason,omitempty"`
+	Client string `json:"client,omitempty"`
 }
 
 type APIResponse struct {
@@ -40,64 +143,466 @@ type APIResponse struct {
 }
 
 var (
-	flagSetup   = flag.Bool("setup", false, "configure server endpoint and API credentials")
-	flagVersion = flag.Bool("version", false, "show version information")
-	flagVerbose = flag.Bool("v", false, "enable verbose output")
-	flagHelp    = flag.Bool("h", false, "show help")
+	flagSetup       = flag.Bool("setup", false, "configure server endpoint and API credentials")
+	flagCheckConfig = flag.Bool("check-config", false, "validate the configuration and exit")
+	flagNoConfig    = flag.Bool("no-config", false, "never read or write the config file; take settings from flags and environment")
+	flagAuto        = flag.Bool("auto", false, "propose the default gateway as server during setup")
+	flagPinCurrent  = flag.Bool("pin-current", false, "pin the server certificate during setup (trust on first use)")
+	optProfile      = flag.String("profile", "", "named server profile to use")
+	optOtherProfile = flag.String("other-profile", "", "with --diff, the profile of the server to compare against")
+	optConfig       = flag.String("config", "", "config file to use instead of ~/.dnscli/config.json")
+	optServer       = flag.String("server", "", "server URL for this run, overriding config and environment")
+	optPort         = flag.Int("port", 0, "API port for this run, replacing the port of the server URL")
+	optAPIKey       = flag.String("apikey", "", "API key for this run, overriding config and environment")
+	optAPIKeyFile   = flag.String("apikey-file", "", "read the API key from this file")
+	flagVersion     = flag.Bool("version", false, "show version information")
+	flagVerbose     = flag.Bool("v", false, "enable verbose output")
+	flagShowSecrets = flag.Bool("show-secrets", false, "print API keys in full in verbose output")
+	flagHelp        = flag.Bool("h", false, "show help")
+	flagRaw         = flag.Bool("raw-output", false, "print the raw response body without any processing")
+	flagSilent      = flag.Bool("silent", false, "suppress all output, report only via exit code")
+	flagQuiet       = flag.Bool("quiet", false, "suppress normal output, still report errors on stderr")
+	flagJSON        = flag.Bool("json", false, "shorthand for --output json")
+	flagCompletion  = flag.String("completion", "", "print a completion script for bash, zsh or fish")
+	flagCompDomains = flag.String("complete-domains", "", "internal: print the domains starting with this prefix, for completion scripts")
+	optColor        = flag.String("color", "auto", "color output: auto, always or never")
+	optOutputFile   = flag.String("output-file", "", "also write the output to this file")
+	optAppend       = flag.Bool("append", false, "append to --output-file instead of truncating it")
+	optLogFile      = flag.String("log-file", "", "append every request and response to this file")
 	
 	cmdList   = flag.Bool("list", false, "list all DNS records")
 	cmdAdd    = flag.Bool("add", false, "add new DNS record")
 	cmdUpdate = flag.Bool("update", false, "update existing DNS record")
+	cmdUpsert = flag.Bool("upsert", false, "add a record, or point an existing domain at --ip")
 	cmdDelete = flag.Bool("delete", false, "delete DNS record")
+	cmdGet    = flag.Bool("get", false, "print the IP address of a single domain")
+	cmdImport = flag.String("import", "", "add records from a JSON or hosts-format file")
+	cmdExport = flag.String("export", "", "write records to a hosts, .json or .csv file")
+	cmdApply  = flag.String("apply", "", "run the steps of a YAML playbook")
+	cmdQuota  = flag.Bool("quota", false, "show record usage against the server limit")
+	cmdPing   = flag.Bool("ping", false, "check the server is reachable and the API key works")
+	cmdStale  = flag.Bool("detect-stale", false, "probe each record's address and report unreachable ones")
+	cmdREPL   = flag.Bool("interactive", false, "read commands from a prompt until quit")
+	cmdDiff   = flag.Bool("diff", false, "compare the records of two servers")
+	cmdRotate = flag.Bool("rotate-key", false, "switch to --new-key after verifying it, or confirm a rotation")
 	
-	optDomain = flag.String("domain", "", "target domain name")
-	optIP     = flag.String("ip", "", "IP address")
-	optNewIP  = flag.String("new-ip", "", "new IP address for update operation")
+	optDomain      = flag.String("domain", "", "target domain name")
+	optIP          = flag.String("ip", "", "IP address")
+	optNewIP       = flag.String("new-ip", "", "new IP address for update operation")
+	optIfCurrent   = flag.String("if-current", "", "with --update, only update while the domain still points at this address")
+	optResolve     = flag.String("resolve", "", "with --add, --update or --upsert, use the address hostname resolves to")
+	optPreferV4    = flag.Bool("prefer-ipv4", false, "with --resolve, pick an IPv4 address when there is one")
+	optPreferV6    = flag.Bool("prefer-ipv6", false, "with --resolve, pick an IPv6 address when there is one")
+	optNewKey      = flag.String("new-key", "", "new API key for --rotate-key")
+	optTTL         = flag.Int("ttl", 0, "with --add or --update, record TTL in seconds")
+	optType        = flag.String("type", "", "with --add or --update, record type: A, AAAA, CNAME, TXT or PTR")
+	optSuffix      = flag.String("domain-suffix", "", "suffix appended to bare hostnames")
+	optPatch       = flag.Bool("patch", false, "send update as PATCH with only the changed fields")
+	optMerge       = flag.Bool("merge-ips", false, "add another address to a domain that already has one")
+	optClip        = flag.Bool("clip", false, "copy the result of --get to the clipboard")
+	optExpect      = flag.String("expect-server", "", "refuse mutations unless the configured server matches")
+	optDryRun      = flag.Bool("dry-run", false, "show what would change without modifying records")
+	optPlanDir     = flag.String("plan-dir", "", "write the dry-run import plan to files in this directory")
+	optOutput      = flag.String("output", "table", "output format: table, csv or json")
+	optNormal      = flag.Bool("normalize", false, "normalize imported domains and addresses before sending")
+	optFlatten     = flag.Bool("flatten", false, "export one row per domain/IP pair instead of grouping")
+	optDedupe      = flag.Bool("dedupe", false, "drop duplicate domain/IP pairs from the import file")
+	optTransform   = flag.String("transform", "", "shell command that rewrites each imported record (JSON on stdin/stdout)")
+	optPrune       = flag.Bool("interactive-delete", false, "with --list, pick records to delete from a numbered list")
+	optYes         = flag.Bool("yes", false, "do not ask for confirmation before deleting")
+	optMatch       = flag.String("match", "", "with --delete, remove every record whose domain matches")
+	optIgnoreMiss  = flag.Bool("ignore-missing", false, "with --delete, succeed when the record does not exist")
+	optBatch       = flag.String("batch", "", "with --add or --delete, read \"domain ip\" pairs from file")
+	optStdin       = flag.Bool("stdin", false, "with --add or --update, read a JSON record or array of records from stdin")
+	optConcurrency = flag.Int("concurrency", 4, "number of --batch requests or --detect-stale probes run in parallel")
+	optRate        = flag.Float64("rate", 0, "with --batch, send at most this many requests per second")
+	optIPFamily    = flag.Int("ip-family", 0, "with --list, show only IPv4 (4) or IPv6 (6) records")
+	optGroupBy     = flag.String("group-by", "", "with --list, group records by group, comment or ip")
+	optTemplate    = flag.String("format-template", "", "with --list, print each record with this Go text/template")
+	optFields      = flag.String("fields", "", "with --list, comma-separated columns to print: domain, ip, type, ttl, comment, group, age")
+	optStaleAfter  = flag.String("stale-after", "", "with --list, show records unchanged for this long (e.g. 30d)")
+	optFailOnStale = flag.Bool("fail-on-stale", false, "exit non-zero when --stale-after matches any record")
+	optFailOnDup   = flag.Bool("fail-on-duplicates", false, "with --list, exit non-zero when a domain has more than one record")
+	optByReason    = flag.String("by-reason", "", "with --list, show records changed for this audit reason")
+	optByClient    = flag.String("by-client", "", "with --list, show records changed by this client")
+	optFilter      = flag.String("filter", "", "with --list, show domains containing this text")
+	optFilterIP    = flag.String("filter-ip", "", "with --list, show addresses containing this text")
+	optSort        = flag.String("sort", "", "with --list, sort by domain or ip")
+	optReverse     = flag.Bool("reverse", false, "with --list, reverse the sort order")
+	optDomainsOnly = flag.Bool("domains-only", false, "with --list, print only domain names")
+	optIPsOnly     = flag.Bool("ips-only", false, "with --list, print only unique IP addresses")
+	optCount       = flag.Bool("count", false, "with --list, print only the number of records")
+	optPageSize    = flag.Int("page-size", 0, "with --list, show this many records at a time on a terminal")
+	optWatch       = flag.Bool("watch", false, "with --list, refresh the list every --interval")
+	optInterval    = flag.Duration("interval", 5*time.Second, "refresh interval for --watch")
+	
+	optCheckDNS  = flag.Bool("check-dns", false, "verify the record resolves after add or update")
+	optDNSServer = flag.String("dns-check-server", "", "DNS server (host[:port]) used for verification")
+	
+	optConnectTimeout = flag.Duration("connect-timeout", 0, "maximum time to establish a connection")
+	optProxy          = flag.String("proxy", "", "proxy URL for API requests, overriding HTTP_PROXY and HTTPS_PROXY")
+	optUserAgent      = flag.String("user-agent", "", "identifier sent in the User-Agent header, followed by the client version")
+	optBasicAuth      = flag.String("basic-auth", "", "user:password for HTTP basic auth, sent along with the API key")
+	optTimeout        = flag.Duration("timeout", 30*time.Second, "overall timeout for each API request, 0 for none")
+	optMinTLS         = flag.String("min-tls", "", "minimum TLS version for HTTPS connections (1.2 or 1.3)")
+	optInsecure       = flag.Bool("insecure", false, "skip TLS certificate verification")
+	optCACert         = flag.String("cacert", "", "trust the CA certificates in this PEM file")
+	optStatsInterval  = flag.Duration("stats-interval", 0, "report bulk progress at this interval")
+	optAbortOnError   = flag.Bool("abort-on-error", false, "stop bulk operations at the first failure")
+	optSummaryJSON    = flag.Bool("summary-json", false, "with --batch, end with a JSON summary of the results")
+	optProbeTimeout   = flag.Duration("probe-timeout", 2*time.Second, "timeout for each --detect-stale probe")
+	optProbePort      = flag.Int("probe-port", 0, "with --detect-stale, check this TCP port instead of pinging")
+	optRetries        = flag.Int("retries", 0, "retry failed requests up to n times (at most 10)")
+	optRetryStatus    = flag.String("retry-status", "", "comma-separated status codes that trigger a retry")
+	
+	optAutoSuffix    = flag.Bool("auto-suffix-from-server", false, "use the server's local domain as the default suffix")
+	optRefreshSuffix = flag.Bool("refresh-suffix", false, "re-query the server's local domain")
 )
 
 func init() {
 	flag.Usage = showUsage
+	flag.BoolVar(flagQuiet, "q", false, "shorthand for --quiet")
+	flag.BoolVar(optYes, "y", false, "shorthand for --yes")
+	flag.StringVar(optOutput, "o", "table", "shorthand for --output")
+	flag.StringVar(optOutput, "format", "table", "same as --output")
 }
 
+// configPath returns the config file location: --config, then the
+// DNSCLI_CONFIG environment variable, then ~/.dnscli/config.json.
 func configPath() string {
+	if *optConfig != "" {
+		return *optConfig
+	}
+	if path := os.Getenv("DNSCLI_CONFIG"); path != "" {
+		return path
+	}
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".dnscli", "config.json")
 }
 
-func loadConfig() (Config, error) {
+// configFile is the on-disk layout: named server profiles plus the name of
+// the one used when --profile is not given. The embedded Config holds the
+// top-level fields of the old single-server format, read only for migration.
+type configFile struct {
+	Config
+	Default  string            `json:"default"`
+	Profiles map[string]Config `json:"profiles"`
+}
+
+// errNoConfig is returned for any config file access under --no-config.
+var errNoConfig = errors.New("the config file is disabled by --no-config")
+
+func readConfigFile() (configFile, error) {
+	if *flagNoConfig {
+		return configFile{}, errNoConfig
+	}
 	path := configPath()
 	file, err := os.Open(path)
 	if err != nil {
-		return Config{}, err
+		return configFile{}, err
 	}
 	defer file.Close()
 	
-	var cfg Config
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
-		return Config{}, err
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return configFile{}, err
 	}
-	return cfg, nil
+	var cf configFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return configFile{}, malformedConfig(path, data, err)
+	}
+	
+	if len(cf.Profiles) == 0 && cf.Server != "" {
+		cf.Profiles = map[string]Config{"default": cf.Config}
+		cf.Default = "default"
+		cf.Config = Config{}
+		if err := writeConfigFile(cf); err != nil {
+			return configFile{}, fmt.Errorf("failed to migrate configuration: %v", err)
+		}
+	}
+	return cf, nil
 }
 
-func saveConfig(cfg Config) error {
+func writeConfigFile(cf configFile) error {
+	if *flagNoConfig {
+		return errNoConfig
+	}
 	dir := filepath.Dir(configPath())
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return err
 	}
 	
-	file, err := os.Create(configPath())
+	// The file holds API keys, so it is kept private to the user. Chmod
+	// covers files created by older versions with the default mode.
+	file, err := os.OpenFile(configPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
 	
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(cfg)
+	return encoder.Encode(struct {
+		Default  string            `json:"default"`
+		Profiles map[string]Config `json:"profiles"`
+	}{cf.Default, cf.Profiles})
+}
+
+// profileName returns the profile selected by --profile, falling back to
+// the configured default.
+func profileName(cf configFile) string {
+	if *optProfile != "" {
+		return *optProfile
+	}
+	if cf.Default != "" {
+		return cf.Default
+	}
+	return "default"
+}
+
+// loadProfile returns the selected profile exactly as stored on disk.
+func loadProfile() (Config, error) {
+	cf, err := readConfigFile()
+	if err != nil {
+		return Config{}, err
+	}
+	
+	name := profileName(cf)
+	cfg, ok := cf.Profiles[name]
+	if !ok {
+		return Config{}, fmt.Errorf("profile %q not found", name)
+	}
+	return cfg, nil
+}
+
+// redactKey masks all but the last four characters of key for display.
+// Keys of four characters or fewer are masked entirely. --show-secrets
+// disables the masking for debugging.
+func redactKey(key string) string {
+	if *flagShowSecrets {
+		return key
+	}
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
+
+// authHeader returns the request header carrying key for the given
+// auth_mode.
+func authHeader(mode, key string) (string, string) {
+	if mode == "bearer" {
+		return "Authorization", "Bearer " + key
+	}
+	return "X-API-Key", key
+}
+
+// setAuth adds the authentication headers of cfg to req, sending apiKey as
+// the key.
+func setAuth(req *http.Request, cfg Config, apiKey string) {
+	req.Header.Set(authHeader(cfg.AuthMode, apiKey))
+	if cfg.BasicAuth != "" {
+		user, password, _ := strings.Cut(cfg.BasicAuth, ":")
+		req.SetBasicAuth(user, password)
+	}
+}
+
+// redactedAuthHeader formats the authentication headers of cfg for verbose
+// output, with the key and the basic auth password redacted.
+func redactedAuthHeader(cfg Config) string {
+	name, _ := authHeader(cfg.AuthMode, "")
+	header := name + ": " + redactKey(cfg.APIKey)
+	if name == "Authorization" {
+		header = name + ": Bearer " + redactKey(cfg.APIKey)
+	}
+	if cfg.BasicAuth != "" {
+		user, _, _ := strings.Cut(cfg.BasicAuth, ":")
+		header += "\n> Authorization: Basic " + user + ":<redacted>"
+	}
+	return header
+}
+
+// malformedConfig describes a config file that exists but cannot be
+// decoded, pointing at the line of a syntax error so it can be fixed by hand
+// rather than recreated.
+func malformedConfig(path string, data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var offset int64
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	// Offset counts the bytes read, including the one that failed.
+	if offset > 0 && offset <= int64(len(data)) {
+		line := 1 + bytes.Count(data[:offset-1], []byte("\n"))
+		return fmt.Errorf("config file %s is malformed (line %d: %v); fix it or move it away and run 'dnscli --setup'", path, line, err)
+	}
+	return fmt.Errorf("config file %s is malformed (%v); fix it or move it away and run 'dnscli --setup'", path, err)
+}
+
+// configError turns a loadConfig failure into the message shown to users.
+// A missing config file points at -setup; other problems, such as only one
+// of the environment variables being set, are reported as they are.
+func configError(err error) error {
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("configuration not found at %s, run 'dnscli -setup' first", configPath())
+	}
+	return &configErr{err}
+}
+
+// loadConfig returns the effective configuration. Values are taken, in
+// order of precedence, from the --server, --apikey and --apikey-file flags,
+// the DNSCLI_SERVER and DNSCLI_APIKEY environment variables and then from
+// the selected profile, where apikey_file wins over apikey. Without a config
+// file the server and a key must both come from flags or the environment.
+// Nothing taken from flags or the environment is ever saved.
+func loadConfig() (Config, error) {
+	if sessionConfig != nil {
+		return *sessionConfig, nil
+	}
+	server := os.Getenv("DNSCLI_SERVER")
+	if *optServer != "" {
+		server = *optServer
+	}
+	apiKey := os.Getenv("DNSCLI_APIKEY")
+	if *optAPIKey != "" {
+		apiKey = *optAPIKey
+	}
+	keyFile := *optAPIKeyFile
+	
+	// With --no-config the file is never looked at, as if it did not
+	// exist, and the settings must all come from flags or environment.
+	var cfg Config
+	var err error
+	if !*flagNoConfig {
+		cfg, err = loadProfile()
+	}
+	if *flagNoConfig || err != nil {
+		if err != nil && (!os.IsNotExist(err) || (server == "" && apiKey == "" && keyFile == "")) {
+			return Config{}, err
+		}
+		if server == "" {
+			return Config{}, fmt.Errorf("no config file and no server given, set DNSCLI_SERVER or --server")
+		}
+		if apiKey == "" && keyFile == "" {
+			return Config{}, fmt.Errorf("no config file and no API key given, set DNSCLI_APIKEY, --apikey or --apikey-file")
+		}
+	}
+	
+	if keyFile == "" && apiKey == "" && cfg.APIKeyFile != "" {
+		if cfg.APIKey != "" {
+			apiKeyFileWarning.Do(func() {
+				fmt.Fprintf(os.Stderr, "dnscli: warning: both apikey and apikey_file are configured, using apikey_file\n")
+			})
+		}
+		keyFile = cfg.APIKeyFile
+	}
+	if keyFile != "" {
+		if apiKey, err = readAPIKeyFile(keyFile); err != nil {
+			return Config{}, err
+		}
+	}
+	
+	switch cfg.AuthMode {
+	case "", "apikey", "bearer":
+	default:
+		return Config{}, fmt.Errorf("unknown auth_mode %q, expected apikey or bearer", cfg.AuthMode)
+	}
+	if *optBasicAuth != "" {
+		cfg.BasicAuth = *optBasicAuth
+	}
+	if cfg.BasicAuth != "" {
+		if !strings.Contains(cfg.BasicAuth, ":") {
+			return Config{}, fmt.Errorf("basic auth must be given as user:password")
+		}
+		if cfg.AuthMode == "bearer" {
+			return Config{}, fmt.Errorf("basic auth cannot be combined with auth_mode bearer, both use the Authorization header")
+		}
+	}
+	
+	if server != "" {
+		cfg.Server = server
+	}
+	if *optPort != 0 {
+		if cfg.Server, err = withPort(cfg.Server, *optPort); err != nil {
+			return Config{}, err
+		}
+	}
+	if apiKey != "" {
+		cfg.APIKey = apiKey
+	}
+	return cfg, nil
+}
+
+// withPort returns server with its port replaced by port, for --port.
+func withPort(server string, port int) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("cannot apply --port to server %q", server)
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+	return u.String(), nil
+}
+
+// readAPIKeyFile returns the key stored in path without its trailing newline.
+func readAPIKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key file: %v", err)
+	}
+	key := strings.TrimRight(string(data), "\r\n")
+	if key == "" {
+		return "", fmt.Errorf("API key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// saveConfig stores cfg as the selected profile, leaving the others intact.
+func saveConfig(cfg Config) error {
+	cf, err := readConfigFile()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	
+	if cf.Profiles == nil {
+		cf.Profiles = make(map[string]Config)
+	}
+	name := profileName(cf)
+	if cf.Default == "" {
+		cf.Default = name
+	}
+	cf.Profiles[name] = cfg
+	return writeConfigFile(cf)
 }
 
 func setupConfig() error {
-	cfg, _ := loadConfig()
+	cf, _ := readConfigFile()
+	name := profileName(cf)
+	
+	fmt.Printf("Profile name [%s]: ", name)
+	var input string
+	fmt.Scanln(&input)
+	if strings.TrimSpace(input) != "" {
+		name = strings.TrimSpace(input)
+	}
+	*optProfile = name
+	
+	cfg, _ := loadProfile()
+	
+	if *flagAuto {
+		if gw, err := defaultGateway(); err == nil {
+			cfg.Server = "http://" + net.JoinHostPort(gw.String(), defaultServerPort)
+			fmt.Printf("Detected default gateway %s\n", gw)
+		} else {
+			fmt.Fprintf(os.Stderr, "dnscli: could not detect default gateway: %v\n", err)
+		}
+	}
 	
 	fmt.Print("Server endpoint")
 	if cfg.Server != "" {
@@ -105,267 +610,2244 @@ func setupConfig() error {
 	}
 	fmt.Print(": ")
 	
-	var input string
+	input = ""
 	fmt.Scanln(&input)
 	if strings.TrimSpace(input) != "" {
 		cfg.Server = strings.TrimSpace(input)
 	}
+	if cfg.Server == "" {
+		return fmt.Errorf("server endpoint is required")
+	}
+	server, err := normalizeServerURL(cfg.Server)
+	if err != nil {
+		return err
+	}
+	if server != cfg.Server {
+		fmt.Printf("Using server endpoint %s\n", server)
+		cfg.Server = server
+	}
 	
 	fmt.Print("API key")
 	if cfg.APIKey != "" {
-		fmt.Printf(" [%s]", cfg.APIKey[:8]+"...")
+		fmt.Printf(" [%s]", redactKey(cfg.APIKey))
 	}
 	fmt.Print(": ")
 	
+	input = ""
 	fmt.Scanln(&input)
 	if strings.TrimSpace(input) != "" {
 		cfg.APIKey = strings.TrimSpace(input)
 	}
 	
-	if cfg.Server == "" {
-		return fmt.Errorf("server endpoint is required")
-	}
 	if cfg.APIKey == "" {
 		return fmt.Errorf("API key is required")
 	}
 	
+	if *flagPinCurrent {
+		pin, err := fetchCertFingerprint(cfg.Server)
+		if err != nil {
+			return fmt.Errorf("failed to read server certificate: %v", err)
+		}
+		cfg.CertPin = pin
+		fmt.Printf("Pinned certificate SHA-256 %s\n", pin)
+		fmt.Println("Warning: this trusts whatever certificate the server presented just now (trust on first use).")
+		fmt.Println("Verify the fingerprint out of band; if the connection was intercepted, the attacker's certificate is now pinned.")
+	}
+	
 	if err := saveConfig(cfg); err != nil {
 		return fmt.Errorf("failed to save configuration: %v", err)
 	}
 	
-	fmt.Println("Configuration saved successfully")
+	fmt.Printf("Configuration saved to profile %q\n", name)
 	return nil
 }
 
-func formatOutput(responseBody []byte, isListCommand bool) {
-	if *flagVerbose {
-		var prettyJSON bytes.Buffer
-		if json.Indent(&prettyJSON, responseBody, "", "  ") == nil {
-			fmt.Print(prettyJSON.String())
-		} else {
-			fmt.Print(string(responseBody))
+// normalizeServerURL turns what was typed at the setup prompt into a base
+// URL: http:// is assumed when no scheme is given, and any path, query or
+// trailing slash is dropped since endpoints are appended to it.
+func normalizeServerURL(server string) (string, error) {
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server endpoint %q: %v", server, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid server endpoint %q: scheme must be http or https", server)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid server endpoint %q: no host", server)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// newHTTPClient builds the client used for all API calls. The overall timeout
+// covers the whole exchange, while --connect-timeout only bounds dialing.
+func newHTTPClient(cfg Config, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *optConnectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   *optConnectTimeout,
+			KeepAlive: 30 * time.Second,
 		}
-		return
+		transport.DialContext = dialer.DialContext
 	}
 	
-	var resp APIResponse
-	if err := json.Unmarshal(responseBody, &resp); err != nil {
-		fmt.Print(string(responseBody))
-		return
-	}
+	transport.Proxy = requestProxy
 	
-	if resp.Error != "" {
-		fmt.Printf("Error: %s\n", resp.Error)
-		return
+	transport.TLSClientConfig = &tls.Config{}
+	if cfg.CertPin != "" {
+		transport.TLSClientConfig = pinnedTLSConfig(cfg.CertPin)
+	}
+	if minTLSVersion != 0 {
+		transport.TLSClientConfig.MinVersion = minTLSVersion
+	}
+	if caPool != nil {
+		transport.TLSClientConfig.RootCAs = caPool
+	}
+	if *optInsecure {
+		transport.TLSClientConfig.InsecureSkipVerify = true
 	}
 	
-	if isListCommand && len(resp.Records) > 0 {
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintf(w, "DOMAIN\tIP ADDRESS\n")
-		for _, record := range resp.Records {
-			fmt.Fprintf(w, "%s\t%s\n", record.Domain, record.IP)
-		}
-		w.Flush()
-		fmt.Printf("\nTotal: %d records\n", len(resp.Records))
-	} else if isListCommand {
-		fmt.Println("No DNS records found")
-	} else {
-		switch resp.Status {
-		case "added":
-			fmt.Printf("✓ Successfully added %s -> %s\n", resp.Domain, resp.IP)
-		case "updated":
-			fmt.Printf("✓ Successfully updated %s -> %s\n", resp.Domain, resp.NewIP)
-		case "deleted":
-			fmt.Printf("✓ Successfully deleted %s\n", resp.Domain)
-		case "exists":
-			fmt.Printf("Record already exists: %s -> %s\n", resp.Domain, resp.IP)
-		default:
-			fmt.Printf("Operation completed: %s\n", resp.Status)
-		}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
 	}
 }
 
-func makeRequest(method, endpoint string, payload interface{}) error {
+// requestUserAgent returns the User-Agent header: --user-agent or the
+// profile's user_agent followed by the client version, else the default.
+func requestUserAgent(cfg Config) string {
+	agent := cfg.UserAgent
+	if *optUserAgent != "" {
+		agent = *optUserAgent
+	}
+	if agent == "" {
+		return userAgent
+	}
+	return agent + " " + userAgent
+}
+
+// requestProxy picks the proxy for req: --proxy when given, otherwise the
+// one named by the environment, if any.
+func requestProxy(req *http.Request) (*url.URL, error) {
+	if proxyURL != nil {
+		return proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// fetchJSON issues an authenticated GET to the server of cfg and decodes the
+// JSON response into v. It is used for internal lookups whose output is never
+// shown to the user, and goes through sendRequest like every other call.
+func fetchJSON(cfg Config, endpoint string, v interface{}) error {
+	resp, body, err := sendRequest(context.Background(), cfg, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if err := statusError(resp, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func fetchDomainSuffix(cfg Config) (string, error) {
+	var result struct {
+		Domain string `json:"domain"`
+	}
+	if err := fetchJSON(cfg, "/domain", &result); err != nil {
+		return "", err
+	}
+	return strings.Trim(strings.TrimSpace(result.Domain), "."), nil
+}
+
+func fetchRecords(cfg Config) ([]Record, error) {
+	var resp APIResponse
+	if err := fetchJSON(cfg, "/dns", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Records, nil
+}
+
+// checkCurrentIP implements --if-current on the client: the addresses the
+// update would replace must be exactly expected. Servers that understand
+// current_ip repeat the check atomically; older ones ignore the field, so
+// this read is the only protection they get.
+func checkCurrentIP(domain, ip, expected string) error {
+	if expected == "" {
+		return nil
+	}
 	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("configuration not found, run 'dnscli -setup' first")
+		return configError(err)
 	}
-	
-	url := strings.TrimSuffix(cfg.Server, "/") + endpoint
-	isListCommand := method == "GET" && endpoint == "/dns"
-	
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	records, err := fetchDomainRecords(cfg, domain)
+	if err != nil {
+		return fmt.Errorf("failed to fetch current records: %w", err)
 	}
 	
-	var body io.Reader
-	if payload != nil {
-		data, err := json.Marshal(payload)
-		if err != nil {
-			return fmt.Errorf("failed to encode request: %v", err)
+	var current []string
+	matches := true
+	for _, r := range records {
+		if ip != "" && r.IP != ip {
+			continue
 		}
-		body = bytes.NewReader(data)
-		
-		if *flagVerbose {
-			fmt.Fprintf(os.Stderr, "> %s %s\n", method, url)
-			fmt.Fprintf(os.Stderr, "> Content-Type: application/json\n")
-			fmt.Fprintf(os.Stderr, "> X-API-Key: %s\n", cfg.APIKey[:8]+"...")
-			fmt.Fprintf(os.Stderr, ">\n%s\n", string(data))
+		current = append(current, r.IP)
+		if r.IP != expected {
+			matches = false
 		}
-	} else if *flagVerbose {
-		fmt.Fprintf(os.Stderr, "> %s %s\n", method, url)
-		fmt.Fprintf(os.Stderr, "> X-API-Key: %s\n", cfg.APIKey[:8]+"...")
+	}
+	if len(current) == 0 {
+		return fmt.Errorf("%s: not found", domain)
+	}
+	if !matches {
+		return fmt.Errorf("%s resolves to %s, not %s; not updating", domain, strings.Join(current, ", "), expected)
+	}
+	return nil
+}
+
+// fetchDomainRecords returns the records of a single domain, asking the
+// server's /dns/{domain} endpoint first. A domain the endpoint reports as
+// missing has no records; servers without the endpoint, which answer 405 or
+// a 404 without that JSON error, fall back to searching the full list.
+func fetchDomainRecords(cfg Config, domain string) ([]Record, error) {
+	var resp APIResponse
+	err := fetchJSON(cfg, "/dns/"+url.PathEscape(domain), &resp)
+	if err == nil {
+		return resp.Records, nil
+	}
+	var srvErr *serverError
+	if !errors.As(err, &srvErr) || (srvErr.Code != http.StatusNotFound && srvErr.Code != http.StatusMethodNotAllowed) {
+		return nil, err
+	}
+	var body APIResponse
+	if srvErr.Code == http.StatusNotFound && json.Unmarshal([]byte(srvErr.Body), &body) == nil && body.Error == "not found" {
+		return nil, nil
 	}
 	
-	req, err := http.NewRequest(method, url, body)
+	records, err := fetchRecords(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
-	
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", cfg.APIKey)
-	
-	resp, err := client.Do(req)
+	var matches []Record
+	for _, r := range records {
+		if strings.EqualFold(r.Domain, domain) {
+			matches = append(matches, r)
+		}
+	}
+	return matches, nil
+}
+
+// checkExistingDomain refuses to add a second address for a domain that is
+// already mapped elsewhere, unless --merge-ips asks for a round-robin set.
+func checkExistingDomain(domain, ip string) error {
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return configError(err)
 	}
-	defer resp.Body.Close()
 	
-	responseBody, err := io.ReadAll(resp.Body)
+	records, err := fetchRecords(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
+		return fmt.Errorf("failed to fetch existing records: %w", err)
 	}
 	
-	if *flagVerbose {
-		fmt.Fprintf(os.Stderr, "< HTTP/%s %s\n", resp.Proto[5:], resp.Status)
-		for k, v := range resp.Header {
-			fmt.Fprintf(os.Stderr, "< %s: %s\n", k, strings.Join(v, ", "))
+	var others []string
+	for _, r := range records {
+		if r.Domain != domain {
+			continue
 		}
-		fmt.Fprintf(os.Stderr, "<\n")
+		if r.IP == ip {
+			return nil
+		}
+		others = append(others, r.IP)
+	}
+	
+	if len(others) > 0 {
+		return fmt.Errorf("%s already resolves to %s, use --merge-ips to add another address or --update to replace it", domain, strings.Join(others, ", "))
+	}
+	return nil
+}
+
+// upsertRecord makes r.Domain resolve to r.IP. A domain that already points
+// elsewhere is updated, replacing its addresses; a new domain is added.
+func upsertRecord(r Record) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch existing records: %w", err)
+	}
+	
+	current, other := false, false
+	for _, e := range records {
+		if e.Domain == r.Domain {
+			current = current || e.IP == r.IP
+			other = other || e.IP != r.IP
+		}
+	}
+	
+	switch {
+	case other:
+		return makeRequest("PUT", "/dns", Record{Domain: r.Domain, NewIP: r.IP, TTL: r.TTL, Type: r.Type})
+	case current:
+		if *optOutput == "json" {
+			return printJSON(opResult{Status: "exists", Domain: r.Domain, IP: r.IP})
+		}
+		fmt.Println(colorize(colorGreen, fmt.Sprintf("✓ %s already resolves to %s", r.Domain, r.IP)))
+		return nil
+	}
+	warnQuota(1)
+	return makeRequest("POST", "/dns", r)
+}
+
+// domainSuffix returns the suffix to append to bare hostnames. An explicit
+// --domain-suffix wins; otherwise the suffix cached in the config is used,
+// fetched from the server first when requested.
+func domainSuffix() string {
+	if *optSuffix != "" {
+		return strings.Trim(*optSuffix, ".")
+	}
+	
+	cfg, err := loadConfig()
+	if err != nil {
+		return ""
+	}
+	
+	if *optRefreshSuffix || (*optAutoSuffix && cfg.DomainSuffix == "") {
+		suffix, err := fetchDomainSuffix(cfg)
+		if err != nil {
+			if *flagVerbose {
+				fmt.Fprintf(os.Stderr, "dnscli: could not fetch domain suffix: %v\n", err)
+			}
+			return cfg.DomainSuffix
+		}
+		if suffix != cfg.DomainSuffix {
+			cfg.DomainSuffix = suffix
+			if stored, err := loadProfile(); err == nil {
+				stored.DomainSuffix = suffix
+				if err := saveConfig(stored); err != nil {
+					fmt.Fprintf(os.Stderr, "dnscli: failed to cache domain suffix: %v\n", err)
+				}
+			}
+		}
+	}
+	return cfg.DomainSuffix
+}
+
+// qualifyDomain appends the domain suffix to a bare hostname and
+// normalizes the result.
+func qualifyDomain(domain string) string {
+	return normalizeDomain(appendSuffix(domain))
+}
+
+// appendSuffix appends the domain suffix to a bare hostname and leaves any
+// other name as it is. A trailing dot marks a name as already complete, so
+// "host." is taken as is rather than qualified.
+func appendSuffix(domain string) string {
+	if domain == "" || strings.Contains(domain, ".") {
+		return domain
+	}
+	if suffix := domainSuffix(); suffix != "" {
+		return domain + "." + suffix
+	}
+	return domain
+}
+
+// normalizeDomain lowercases domain and drops a trailing dot, matching how
+// names are stored, since DNS compares them case-insensitively. A leading
+// "*." wildcard is kept.
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// normalizeRecord puts a record into the canonical form stored by the
+// server: lowercase domain without a trailing dot, bare hostnames qualified
+// with the suffix, and IPs in their canonical textual form.
+func normalizeRecord(r Record) Record {
+	r.Domain = qualifyDomain(r.Domain)
+	if ip := net.ParseIP(strings.TrimSpace(r.IP)); ip != nil {
+		r.IP = ip.String()
+	}
+	return r
+}
+
+// dnsResolver returns a resolver that queries the dnsmasq instance directly.
+// Without --dns-check-server the API host is assumed to also serve DNS.
+func dnsResolver(cfg Config) (*net.Resolver, error) {
+	addr := *optDNSServer
+	if addr == "" {
+		u, err := url.Parse(cfg.Server)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("cannot derive DNS server from %q", cfg.Server)
+		}
+		addr = u.Hostname()
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+	
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, addr)
+		},
+	}, nil
+}
+
+func checkDNS(domain, ip string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	
+	resolver, err := dnsResolver(cfg)
+	if err != nil {
+		return err
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	
+	addrs, err := resolver.LookupHost(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("DNS check failed: %v", err)
+	}
+	
+	for _, addr := range addrs {
+		if net.ParseIP(addr).Equal(net.ParseIP(ip)) {
+			fmt.Println(colorize(colorGreen, fmt.Sprintf("✓ %s resolves to %s", domain, ip)))
+			return nil
+		}
+	}
+	return fmt.Errorf("DNS check failed: %s resolves to %s, expected %s", domain, strings.Join(addrs, ", "), ip)
+}
+
+// getRecord prints the address(es) of a single domain, one per line, so the
+// output can be used directly in shell substitutions.
+func getRecord(domain string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	
+	records, err := fetchDomainRecords(cfg, domain)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", domain, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s: not found", domain)
+	}
+	
+	if *optOutput == "json" {
+		if len(records) == 1 {
+			return printJSON(records[0])
+		}
+		return printJSON(records)
+	}
+	
+	var ips []string
+	for _, r := range records {
+		ips = append(ips, r.IP)
+	}
+	output := strings.Join(ips, "\n")
+	fmt.Println(output)
+	
+	if *optClip {
+		if err := copyToClipboard(output); err != nil {
+			return fmt.Errorf("could not copy to clipboard: %v", err)
+		}
+	}
+	return nil
+}
+
+// filterRecords applies the client-side list filters and the --sort order.
+func filterRecords(records []Record) []Record {
+	var filtered []Record
+	for _, r := range records {
+		if matchesFilters(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	if *optSort != "" || *optReverse {
+		orderRecords(filtered, *optSort, *optReverse)
+	}
+	return filtered
+}
+
+func matchesFilters(r Record) bool {
+	if *optFilter != "" && !domainMatches(*optFilter, r.Domain) {
+		return false
+	}
+	if *optFilterIP != "" && !strings.Contains(strings.ToLower(r.IP), strings.ToLower(*optFilterIP)) {
+		return false
+	}
+	
+	if *optIPFamily != 0 {
+		ip := net.ParseIP(r.IP)
+		if ip == nil || (ip.To4() != nil) != (*optIPFamily == 4) {
+			return false
+		}
+	}
+	
+	if *optByReason != "" && !strings.EqualFold(r.Reason, *optByReason) {
+		return false
+	}
+	if *optByClient != "" && !strings.EqualFold(r.Client, *optByClient) {
+		return false
+	}
+	
+	if staleAfter > 0 {
+		age, ok := recordAge(r)
+		if !ok || age < staleAfter {
+			return false
+		}
+	}
+	return true
+}
+
+// domainMatches compares domain against pattern ignoring case. Patterns with
+// glob characters must match the whole domain (see path.Match), anything else
+// matches as a substring.
+func domainMatches(pattern, domain string) bool {
+	pattern, domain = strings.ToLower(pattern), strings.ToLower(domain)
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := path.Match(pattern, domain)
+		return ok
+	}
+	return strings.Contains(domain, pattern)
+}
+
+// orderRecords sorts records in place by domain or by address. Addresses are
+// compared numerically, IPv4 before IPv6, so 192.168.1.9 sorts before
+// 192.168.1.10. Without a field the server order is kept and only reversed.
+func orderRecords(records []Record, field string, reverse bool) {
+	less := func(a, b Record) bool { return false }
+	switch field {
+	case "domain":
+		less = func(a, b Record) bool { return strings.ToLower(a.Domain) < strings.ToLower(b.Domain) }
+	case "ip":
+		less = func(a, b Record) bool { return compareIP(a.IP, b.IP) < 0 }
+	}
+	
+	sort.SliceStable(records, func(i, j int) bool {
+		return less(records[i], records[j])
+	})
+	if reverse {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+}
+
+// compareIP orders addresses numerically. Values that do not parse sort
+// after all valid addresses, in lexical order.
+func compareIP(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipA == nil && ipB == nil:
+		return strings.Compare(a, b)
+	case ipA == nil:
+		return 1
+	case ipB == nil:
+		return -1
+	}
+	
+	v4A, v4B := ipA.To4() != nil, ipB.To4() != nil
+	if v4A != v4B {
+		if v4A {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+// printColumn prints the bare domain or IP of each record, one per line,
+// for piping into other tools. IPs are printed once each.
+func printColumn(records []Record) {
+	seen := make(map[string]bool)
+	for _, r := range records {
+		if *optDomainsOnly {
+			fmt.Println(r.Domain)
+			continue
+		}
+		if !seen[r.IP] {
+			seen[r.IP] = true
+			fmt.Println(r.IP)
+		}
+	}
+}
+
+// printTemplate prints each record through --format-template, one line per
+// record.
+func printTemplate(records []Record) error {
+	w := bufio.NewWriter(os.Stdout)
+	for _, r := range records {
+		if err := recordTemplate.Execute(w, r); err != nil {
+			return err
+		}
+		w.WriteString("\n")
+	}
+	return w.Flush()
+}
+
+func printTable(records []Record) {
+	writeTable(records, tableColumns(records))
+}
+
+// listFields is the parsed value of --fields: the list columns to print, in
+// order. Nil picks them automatically.
+var listFields []string
+
+// fieldNames are the columns accepted by --fields.
+var fieldNames = []string{"domain", "ip", "type", "ttl", "comment", "group", "age"}
+
+// parseFields checks a comma-separated --fields value against fieldNames.
+func parseFields(value string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(value, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !contains(fieldNames, f) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", f, strings.Join(fieldNames, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// tableColumns returns the columns to print for records: --fields when
+// given, otherwise domain and ip plus the optional columns records need. It
+// is computed over the whole list so that every page of a paged listing has
+// the same columns.
+func tableColumns(records []Record) []string {
+	if listFields != nil {
+		return listFields
+	}
+	
+	showTTL, showType, showAge := false, false, staleAfter > 0
+	for _, r := range records {
+		if r.TTL > 0 {
+			showTTL = true
+		}
+		if _, ok := recordAge(r); ok {
+			showAge = true
+		}
+		if t := recordType(r); t != "A" && t != "AAAA" {
+			showType = true
+		}
+	}
+	columns := []string{"domain", "ip"}
+	if showType {
+		columns = []string{"domain", "type", "ip"}
+	}
+	if showTTL {
+		columns = append(columns, "ttl")
+	}
+	if showAge {
+		columns = append(columns, "age")
+	}
+	return columns
+}
+
+// fieldValue returns the text of column field for r.
+func fieldValue(r Record, field string) string {
+	switch field {
+	case "domain":
+		if ip := net.ParseIP(r.Domain); ip != nil && recordType(r) == "PTR" {
+			return reverseName(ip)
+		}
+		return r.Domain
+	case "ip":
+		return r.IP
+	case "type":
+		return recordType(r)
+	case "ttl":
+		if r.TTL > 0 {
+			return strconv.Itoa(r.TTL)
+		}
+	case "comment":
+		return r.Comment
+	case "group":
+		return r.Group
+	case "age":
+		// Records without a timestamp leave the cell empty, and a server
+		// clock slightly ahead of ours shows as just changed.
+		if age, ok := recordAge(r); ok {
+			if age < 0 {
+				age = 0
+			}
+			return formatAge(age)
+		}
+	}
+	return ""
+}
+
+func writeTable(records []Record, columns []string) {
+	// Mixed record sets get a TYPE column, and the ip column holds names
+	// and text as well as addresses.
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var header []string
+	for _, c := range columns {
+		switch {
+		case c == "ip" && contains(columns, "type"):
+			header = append(header, "VALUE")
+		case c == "ip":
+			header = append(header, "IP ADDRESS")
+		default:
+			header = append(header, strings.ToUpper(c))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	
+	for _, record := range records {
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			values[i] = fieldValue(record, c)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	w.Flush()
+}
+
+// printPaged prints records size at a time, waiting for Enter between pages.
+// Answering q stops early.
+func printPaged(records []Record, size int) {
+	columns := tableColumns(records)
+	reader := bufio.NewReader(os.Stdin)
+	for start := 0; start < len(records); start += size {
+		end := start + size
+		if end > len(records) {
+			end = len(records)
+		}
+		writeTable(records[start:end], columns)
+		if end == len(records) {
+			return
+		}
+		
+		fmt.Printf("-- %d of %d records, Enter for more, q to quit -- ", end, len(records))
+		answer, err := reader.ReadString('\n')
+		if err != nil || strings.EqualFold(strings.TrimSpace(answer), "q") {
+			fmt.Println()
+			return
+		}
+		fmt.Println()
+	}
+}
+
+// printGroupedTable prints one table per distinct value of field, in sorted
+// order, with records lacking the field collected under "(ungrouped)".
+func printGroupedTable(records []Record, field string) {
+	groups := make(map[string][]Record)
+	for _, r := range records {
+		groups[recordField(r, field)] = append(groups[recordField(r, field)], r)
+	}
+	
+	var names []string
+	for name := range groups {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[""]; ok {
+		names = append(names, "")
+	}
+	
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		if name == "" {
+			fmt.Println("(ungrouped)")
+		} else {
+			fmt.Printf("[%s]\n", name)
+		}
+		printTable(groups[name])
+	}
+}
+
+// recordField returns the value of a groupable record field.
+func recordField(r Record, field string) string {
+	switch field {
+	case "group":
+		return r.Group
+	case "comment":
+		return r.Comment
+	case "ip":
+		return r.IP
+	}
+	return ""
+}
+
+// opResult is the normalized --output json form of an add, update or delete
+// response.
+type opResult struct {
+	Status string `json:"status"`
+	Domain string `json:"domain,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	NewIP  string `json:"new_ip,omitempty"`
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// formatJSON renders a response in the stable --output json schema: an
+// array of records for list, a single object for everything else.
+func formatJSON(resp APIResponse, isListCommand bool) error {
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if isListCommand {
+		records := filterRecords(resp.Records)
+		// Without --sort, lists come out in the same stable order as
+		// exports rather than in server order.
+		if *optSort == "" {
+			records = sortRecords(records)
+			orderRecords(records, "", *optReverse)
+		}
+		if records == nil {
+			records = []Record{}
+		}
+		return printJSON(records)
+	}
+	return printJSON(opResult{Status: resp.Status, Domain: resp.Domain, IP: resp.IP, NewIP: resp.NewIP})
+}
+
+// formatCSV renders a list as domain,ip rows and any other response as a
+// single status row, each preceded by a header row.
+func formatCSV(resp APIResponse, isListCommand bool) error {
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if isListCommand && listFields != nil {
+		cw := csv.NewWriter(os.Stdout)
+		cw.Write(listFields)
+		for _, r := range filterRecords(resp.Records) {
+			row := make([]string, len(listFields))
+			for i, f := range listFields {
+				row[i] = fieldValue(r, f)
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	if isListCommand {
+		return writeCSVRecords(os.Stdout, filterRecords(resp.Records))
+	}
+	
+	cw := csv.NewWriter(os.Stdout)
+	cw.Write([]string{"status", "domain", "ip", "new_ip"})
+	cw.Write([]string{resp.Status, resp.Domain, resp.IP, resp.NewIP})
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatOutput(responseBody []byte, isListCommand bool) error {
+	if isListCommand && *optCount {
+		var resp APIResponse
+		if err := json.Unmarshal(responseBody, &resp); err != nil {
+			return fmt.Errorf("invalid JSON response: %v", err)
+		}
+		fmt.Println(len(filterRecords(resp.Records)))
+		return nil
+	}
+	
+	if *optOutput == "json" || *optOutput == "csv" {
+		var resp APIResponse
+		if err := json.Unmarshal(responseBody, &resp); err != nil {
+			return fmt.Errorf("invalid JSON response: %v", err)
+		}
+		if *optOutput == "csv" {
+			return formatCSV(resp, isListCommand)
+		}
+		return formatJSON(resp, isListCommand)
+	}
+	
+	if *flagVerbose {
+		var prettyJSON bytes.Buffer
+		if json.Indent(&prettyJSON, responseBody, "", "  ") == nil {
+			fmt.Print(prettyJSON.String())
+		} else {
+			fmt.Print(string(responseBody))
+		}
+		return nil
+	}
+	
+	var resp APIResponse
+	if err := json.Unmarshal(responseBody, &resp); err != nil {
+		fmt.Print(string(responseBody))
+		return nil
+	}
+	
+	if resp.Error != "" {
+		fmt.Println(colorize(colorRed, "Error: "+resp.Error))
+		return nil
+	}
+	
+	total := len(resp.Records)
+	if isListCommand {
+		resp.Records = filterRecords(resp.Records)
+	}
+	
+	if isListCommand && (*optDomainsOnly || *optIPsOnly) {
+		printColumn(resp.Records)
+		return nil
+	}
+	
+	if isListCommand && recordTemplate != nil {
+		return printTemplate(resp.Records)
+	}
+	
+	if isListCommand && len(resp.Records) > 0 {
+		switch {
+		case *optGroupBy != "":
+			printGroupedTable(resp.Records, *optGroupBy)
+		case *optPageSize > 0 && isTerminal(os.Stdout) && isTerminal(os.Stdin):
+			printPaged(resp.Records, *optPageSize)
+		default:
+			printTable(resp.Records)
+		}
+		if len(resp.Records) < total {
+			fmt.Printf("\n%s\n", colorize(colorDim, fmt.Sprintf("Showing %d of %d records", len(resp.Records), total)))
+		} else {
+			fmt.Printf("\n%s\n", colorize(colorDim, fmt.Sprintf("Total: %d records", len(resp.Records))))
+		}
+	} else if isListCommand && total > 0 {
+		fmt.Printf("No records match the filters (%d total)\n", total)
+	} else if isListCommand {
+		fmt.Println("No DNS records found")
+	} else {
+		switch resp.Status {
+		case "added":
+			fmt.Println(colorize(colorGreen, fmt.Sprintf("✓ Successfully added %s -> %s", resp.Domain, resp.IP)))
+		case "updated":
+			// Servers that report the previous address get it logged too.
+			if resp.IP != "" {
+				fmt.Println(colorize(colorGreen, fmt.Sprintf("✓ Successfully updated %s: %s -> %s", resp.Domain, resp.IP, resp.NewIP)))
+			} else {
+				fmt.Println(colorize(colorGreen, fmt.Sprintf("✓ Successfully updated %s -> %s", resp.Domain, resp.NewIP)))
+			}
+		case "deleted":
+			fmt.Println(colorize(colorGreen, "✓ Successfully deleted "+resp.Domain))
+		case "exists":
+			fmt.Printf("Record already exists: %s -> %s\n", resp.Domain, resp.IP)
+		default:
+			fmt.Printf("Operation completed: %s\n", resp.Status)
+		}
+	}
+	return nil
+}
+
+// reportAbsent prints the outcome of a --delete --ignore-missing whose
+// record was already gone, in the same form as a successful delete.
+func reportAbsent(domain string) error {
+	if *optOutput == "json" {
+		return printJSON(opResult{Status: "absent", Domain: domain})
+	}
+	fmt.Printf("%s already absent\n", domain)
+	return nil
+}
+
+// checkClockSkew warns once per run when the server's Date header differs
+// from the local clock by more than maxClockSkew.
+func checkClockSkew(resp *http.Response) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	
+	skew := time.Since(date)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		skewWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "dnscli: warning: server clock differs from local time by %s\n", skew.Round(time.Second))
+		})
+	}
+}
+
+// doRequest sends a single request authenticated with apiKey, which may be
+// a previous key rather than cfg.APIKey, and returns the response with its
+// body already read.
+func doRequest(ctx context.Context, client *http.Client, method, url string, data []byte, cfg Config, apiKey string) (*http.Response, []byte, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	
+	req.Header.Set("User-Agent", requestUserAgent(cfg))
+	req.Header.Set("Content-Type", "application/json")
+	setAuth(req, cfg, apiKey)
+	
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		logExchange(method, url, data, cfg.AuthMode, start, "", nil, err)
+		return nil, nil, fmt.Errorf("request failed: %w", &networkError{err})
+	}
+	defer resp.Body.Close()
+	
+	responseBody, err := io.ReadAll(resp.Body)
+	logExchange(method, url, data, cfg.AuthMode, start, resp.Status, responseBody, err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return resp, responseBody, nil
+}
+
+// apiRequest sends a single API call and returns the response without
+// interpreting its status, so callers can decide how to report it.
+func apiRequest(ctx context.Context, method, endpoint string, payload interface{}) (*http.Response, []byte, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, configError(err)
+	}
+	return sendRequest(ctx, cfg, method, endpoint, payload)
+}
+
+// sendRequest sends a single API call to the server of cfg. It is the one
+// path for all requests, so every call gets the same retries, previous key
+// fallback, dry-run handling and verbose trace.
+func sendRequest(ctx context.Context, cfg Config, method, endpoint string, payload interface{}) (*http.Response, []byte, error) {
+	if method != "GET" && *optExpect != "" {
+		if !strings.EqualFold(strings.TrimSuffix(cfg.Server, "/"), strings.TrimSuffix(*optExpect, "/")) {
+			return nil, nil, fmt.Errorf("configured server %s does not match expected %s, refusing to %s", cfg.Server, *optExpect, method)
+		}
+	}
+	
+	url := strings.TrimSuffix(cfg.Server, "/") + endpoint
+	
+	client := newHTTPClient(cfg, *optTimeout)
+	
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		
+		if *flagVerbose {
+			fmt.Fprintf(os.Stderr, "> %s %s\n", method, url)
+			fmt.Fprintf(os.Stderr, "> Content-Type: application/json\n")
+			fmt.Fprintf(os.Stderr, "> %s\n", redactedAuthHeader(cfg))
+			fmt.Fprintf(os.Stderr, ">\n%s\n", string(data))
+		}
+	} else if *flagVerbose {
+		fmt.Fprintf(os.Stderr, "> %s %s\n", method, url)
+		fmt.Fprintf(os.Stderr, "> %s\n", redactedAuthHeader(cfg))
+	}
+	if *flagVerbose {
+		if req, err := http.NewRequest(method, url, nil); err == nil {
+			if proxy, err := requestProxy(req); err != nil {
+				fmt.Fprintf(os.Stderr, "* invalid proxy setting: %v\n", err)
+			} else if proxy != nil {
+				fmt.Fprintf(os.Stderr, "* via proxy %s\n", proxy.Redacted())
+			} else {
+				fmt.Fprintf(os.Stderr, "* no proxy\n")
+			}
+		}
+	}
+	
+	// With --dry-run, changes are printed instead of sent. Reads still go
+	// out so that pre-checks see the real state of the server.
+	if *optDryRun && method != "GET" {
+		fmt.Printf("%s %s\n%s\n", method, url, data)
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK"}, nil, nil
+	}
+	
+	resp, responseBody, err := sendWithRetry(ctx, client, method, url, data, cfg, cfg.APIKey)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && cfg.PreviousAPIKey != "" {
+		fmt.Fprintf(os.Stderr, "dnscli: API key rejected, retrying with the previous key\n")
+		resp, responseBody, err = sendWithRetry(ctx, client, method, url, data, cfg, cfg.PreviousAPIKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	if *flagVerbose {
+		fmt.Fprintf(os.Stderr, "< HTTP/%s %s\n", resp.Proto[5:], resp.Status)
+		for k, v := range resp.Header {
+			fmt.Fprintf(os.Stderr, "< %s: %s\n", k, strings.Join(v, ", "))
+		}
+		fmt.Fprintf(os.Stderr, "<\n")
+	}
+	
+	checkClockSkew(resp)
+	return resp, responseBody, nil
+}
+
+// statusError reports a non-2xx response as an error.
+func statusError(resp *http.Response, body []byte) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text := string(body)
+		if !*flagVerbose && !isJSONResponse(resp) {
+			text = bodySnippet(text)
+		}
+		srvErr := &serverError{Code: resp.StatusCode, Status: resp.Status, Body: text}
+		if resp.Request != nil {
+			srvErr.Endpoint = resp.Request.URL.Path
+		}
+		return srvErr
+	}
+	return nil
+}
+
+// errorSnippetLength bounds how much of a non-JSON error body is shown.
+const errorSnippetLength = 80
+
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// bodySnippet shortens an error page, typically HTML from a reverse proxy,
+// to its first words of text. -v shows the body in full.
+func bodySnippet(body string) string {
+	var text strings.Builder
+	inTag := false
+	for _, r := range body {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			text.WriteRune(' ')
+		case !inTag:
+			text.WriteRune(r)
+		}
+	}
+	snippet := strings.Join(strings.Fields(text.String()), " ")
+	if len(snippet) > errorSnippetLength {
+		snippet = strings.TrimSpace(snippet[:errorSnippetLength]) + "..."
+	}
+	return snippet
+}
+
+func makeRequest(method, endpoint string, payload interface{}) error {
+	resp, responseBody, err := apiRequest(context.Background(), method, endpoint, payload)
+	if err != nil {
+		return err
+	}
+	
+	// --raw-output prints error bodies verbatim too, but a failed request
+	// still sets the exit status.
+	if *flagRaw {
+		if _, err := os.Stdout.Write(responseBody); err != nil {
+			return err
+		}
+		return statusError(resp, responseBody)
+	}
+	
+	if err := statusError(resp, responseBody); err != nil {
+		return err
+	}
+	if *optDryRun && method != "GET" {
+		return nil
+	}
+	
+	isListCommand := method == "GET" && endpoint == "/dns"
+	if err := formatOutput(responseBody, isListCommand); err != nil {
+		return err
+	}
+	if isListCommand {
+		var resp APIResponse
+		if json.Unmarshal(responseBody, &resp) == nil {
+			records := filterRecords(resp.Records)
+			if staleAfter > 0 && *optFailOnStale && len(records) > 0 {
+				return fmt.Errorf("%d records not changed in %s", len(records), *optStaleAfter)
+			}
+			return checkDuplicates(records)
+		}
+	}
+	return nil
+}
+
+// checkDuplicates warns on stderr about domains listed with more than one
+// record, which may be a deliberate round-robin set but is often a leftover.
+// With --fail-on-duplicates they are an error.
+func checkDuplicates(records []Record) error {
+	counts := make(map[string]int)
+	var dups []string
+	for _, r := range records {
+		counts[r.Domain]++
+		if counts[r.Domain] == 2 {
+			dups = append(dups, r.Domain)
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	
+	if !*flagQuiet {
+		var parts []string
+		for _, d := range dups {
+			parts = append(parts, fmt.Sprintf("%s (%d)", d, counts[d]))
+		}
+		fmt.Fprintf(os.Stderr, "dnscli: warning: %d domains have more than one record: %s\n", len(dups), strings.Join(parts, ", "))
+	}
+	if *optFailOnDup {
+		return fmt.Errorf("%d duplicate domains found", len(dups))
+	}
+	return nil
+}
+
+func showUsage() {
+	fmt.Printf(`dnscli - DNS record management client %s
+
+USAGE:
+    dnscli [OPTIONS] COMMAND [ARGS]
+
+OPTIONS:
+    -h, --help      show this help message
+    -v, --verbose   enable verbose output
+    -q, --quiet     print nothing on stdout; errors still go to stderr
+    --show-secrets  print the API key in full in verbose output and prompts
+                    (redacted to the last 4 characters by default)
+    --version       show the client version and, when a server is
+                    configured, the server's (flagging a mismatch)
+    --raw-output    print the response body exactly as received
+    --silent        print nothing, not even errors; only the exit code
+                    reports the result (drop it to see diagnostics)
+    --domain-suffix <suffix>
+                    append suffix to bare hostnames
+    --auto-suffix-from-server
+                    use the router's local domain as the default suffix
+    --refresh-suffix
+                    re-query the router's local domain
+    --expect-server <url>
+                    refuse to modify records on any other server
+    -o, --output, --format <table|csv|json>
+                    output format; csv prints domain,ip rows with a header,
+                    json prints records (by domain, then IP, unless
+                    --sort is given) or the operation result as JSON,
+                    and errors as {"error", "code", "endpoint"} on stdout
+    --json          same as --output json
+    --color <auto|always|never>
+                    color status lines; auto colors only on a terminal and
+                    honours NO_COLOR
+    --output-file <path>
+                    also write everything printed on stdout to path,
+                    replacing its contents (never colored)
+    --append        append to --output-file instead of replacing it
+    --log-file <path>
+                    append a timestamped record of every request and
+                    response to path, with the API key redacted
+    --dry-run       print the method, URL and JSON body of each change
+                    instead of sending it (plans for --import and --apply)
+    --timeout <duration>
+                    give up on a request after duration (default 30s, 0s
+                    waits indefinitely)
+    --connect-timeout <duration>
+                    fail if no connection is made within duration (e.g. 3s)
+    --user-agent <string>
+                    send string in the User-Agent header (also user_agent in
+                    config), followed by dnscli/<version>
+    --basic-auth <user:password>
+                    also send HTTP basic auth credentials, for a reverse
+                    proxy that requires them (also basic_auth in config);
+                    the password is redacted in verbose output
+    --proxy <url>   send requests through this proxy (http, https or
+                    socks5); without it HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+                    apply
+    --abort-on-error
+                    stop import, --batch, --apply and multi-delete at the
+                    first failure
+    --summary-json  end --batch with {"total", "succeeded", "failed",
+                    "skipped", "duration_ms"} instead of the summary line;
+                    with --quiet only this object is printed
+    --concurrency <n>
+                    number of --batch requests, or --detect-stale probes,
+                    in flight at once (default 4)
+    --rate <n>      start at most n --batch requests per second across all
+                    of them (e.g. 2, or 0.5 for one every two seconds)
+    --stats-interval <duration>
+                    print import progress and ETA periodically (TTY only)
+    --min-tls <1.2|1.3>
+                    refuse HTTPS connections below this TLS version
+    --cacert <path> trust the CA certificates in this PEM file, e.g. for a
+                    router with a self-signed certificate
+    --insecure      skip certificate verification entirely (prefer --cacert)
+    --retries <n>   retry connection errors and 5xx responses up to n times,
+                    waiting 500ms, 1s, 2s, ... between attempts
+    --retry-status <codes>
+                    status codes to retry, e.g. 502,503,504 (default: 5xx)
+    --check-dns     verify the record resolves after add or update (not
+                    with --batch or --stdin)
+    --dns-check-server <host[:port]>
+                    resolver to verify against (default: API host)
+    --setup         configure server endpoint and credentials
+    --check-config  check the config file permissions, server URL and API
+                    key without contacting the server
+    --profile <name>
+                    use the named server profile instead of the default
+    --config <path> read and write this config file instead of
+                    ~/.dnscli/config.json (also DNSCLI_CONFIG)
+    --no-config     never read or write a config file; the server and API
+                    key must come from flags or DNSCLI_SERVER/DNSCLI_APIKEY
+    --server <url>  use this server for this run only
+    --port <n>      use port n of the configured server for this run
+    --apikey <key>  use this API key for this run only (visible to other
+                    local users in the process list; prefer --apikey-file)
+    --apikey-file <path>
+                    read the API key from path (also apikey_file in config)
+    --auto          with --setup, propose the default gateway as the server
+    --pin-current   with --setup, pin the server's current TLS certificate
+
+COMMANDS:
+    --list                                  list all DNS records, with an AGE
+                                            column (e.g. 3d, 5m) when the server
+                                            reports when records last changed
+    --list --interactive-delete             choose records to delete by number
+    --list --ip-family <4|6>                list only IPv4 or IPv6 records
+    --list --filter <text> [--filter-ip <text>]
+                                            list records whose domain (or IP)
+                                            contains text, ignoring case; a
+                                            domain glob such as *.lab.lan must
+                                            match the whole name
+    --list --watch [--interval <duration>]  redraw the list every interval
+                                            (default 5s) until Ctrl-C
+    --list --page-size <n>                  show n records at a time, pausing
+                                            between pages on a terminal
+    --list --count                          print only the number of records,
+                                            after any filters
+    --list --sort <domain|ip> [--reverse]   list sorted by domain or numerically
+                                            by IP address
+    --list --stale-after <age> [--fail-on-stale]
+                                            list records unchanged for age
+                                            (e.g. 30d), with an AGE column
+    --list --fail-on-duplicates             exit 1 when a domain has several
+                                            records (always warned about on
+                                            stderr unless --quiet)
+    --list --by-reason <reason> | --by-client <client>
+                                            list records by audit metadata
+    --list --domains-only | --ips-only      print only the domain or unique IP
+                                            column, one per line
+    --list --fields <list>                  print only these columns, in order:
+                                            domain, ip, type, ttl, comment,
+                                            group, age (also with -o csv)
+    --list --format-template <template>     print each record with a Go
+                                            text/template, e.g.
+                                            '{{.IP}} {{.Domain}}' for hosts lines
+    --list --group-by <group|comment|ip>    list records in sections by field
+    --add --domain <name> --ip <addr> [--merge-ips] [--ttl <seconds>]
+                                            add new DNS record
+    --add --domain <name> --resolve <host> [--prefer-ipv4|--prefer-ipv6]
+                                            add a record for the address host
+                                            resolves to now (also with
+                                            --update and --upsert)
+    --add --type <CNAME|TXT> --domain <name> --ip <target|text>
+                                            add a CNAME or TXT record; --type
+                                            defaults to A (AAAA for IPv6)
+    --add --type PTR --ip <addr> --domain <host>
+                                            add a reverse lookup record that
+                                            points the address at host
+    --add --batch <file>                    add each "domain ip" line of file
+                                            (- for stdin), reporting a result
+                                            per line
+    --add --stdin | --update --stdin        read a JSON record, or an array of
+                                            them, from stdin and send each one
+                                            (updates need domain and new_ip)
+    --update --domain <name> [--ip <old>] --new-ip <addr> [--patch] [--ttl <s>]
+                                            update existing DNS record
+    --update ... --if-current <addr>        update only if the domain still
+                                            points at addr, else fail without
+                                            changing anything
+    --upsert --domain <name> --ip <addr>    add the record, or update the domain
+                                            to addr if it points elsewhere
+    --delete --domain <name> [--ip <addr>]  delete DNS record, asking first when
+                                            run interactively (-y, --yes skips)
+    --delete ... --ignore-missing           succeed, noting the domain is already
+                                            absent, when the server has no such
+                                            record
+    --delete --match <pattern> [--yes]      delete all records whose domain
+                                            matches, as with --list --filter
+    --delete --batch <file>                 delete each "domain [ip]" line of file
+    --apply <playbook.yaml> [--dry-run]     run ordered add/update/delete/ensure
+                                            steps from a YAML playbook
+    --quota                                 show record usage and server limit
+    --ping                                  check the server is reachable and
+                                            the API key works, with latency
+    --diff --other-profile <name>           compare records with the server of
+                                            profile name, listing domains only
+                                            on one side or with different
+                                            addresses; exits 1 if any differ
+    --interactive                           read list, get, add, update and
+                                            delete commands from a prompt,
+                                            loading the config once
+    --detect-stale [--probe-port <n>]       check each record's host responds to
+                                            ping, or accepts TCP on port n
+    --rotate-key --new-key <key>            switch to a new API key once it has
+                                            been verified, keeping the old one
+                                            as a fallback
+    --rotate-key                            confirm the rotation, dropping the
+                                            old key
+    --get --domain <name> [--clip]          print the IP of a domain, optionally
+                                            copying it to the clipboard; exits 1
+                                            if it has no record (-o json prints
+                                            the record object)
+    --import <file> [--dry-run] [-o json] [--plan-dir <dir>]
+                                            add records from a JSON, .txt batch
+                                            or hosts file, skipping existing ones
+                                            (--normalize cleans up each record,
+                                            --dedupe drops repeated entries,
+                                            --transform <cmd> rewrites each
+                                            record as JSON via stdin/stdout)
+    --export <file> [--flatten]             write records to a .json, .csv,
+                                            .txt (--batch format) or hosts file
+                                            (keeping its comments); --flatten
+                                            gives one row per IP
+
+EXAMPLES:
+    dnscli --setup
+    dnscli --setup --auto
+    dnscli --profile office --list
+    dnscli --list
+    dnscli --add --domain api.example.com --ip 192.168.1.100
+    dnscli --update --domain api.example.com --new-ip 192.168.1.101
+    dnscli --delete --domain api.example.com
+
+DOMAIN NAMES:
+    Domains are case-insensitive: they are lowercased and a trailing dot is
+    dropped before being sent, so API.Example.Com. becomes api.example.com.
+    A trailing dot also keeps --domain-suffix from being appended. Wildcard
+    names such as *.example.com are kept as given.
+
+CONFIGURATION:
+    Settings are resolved in this order, highest precedence first:
+      1. command-line flags: --server, --apikey, --apikey-file
+      2. DNSCLI_SERVER and DNSCLI_APIKEY environment variables
+      3. the selected profile in the config file, where apikey_file
+         takes precedence over apikey
+    The config file is --config, else $DNSCLI_CONFIG, else
+    ~/.dnscli/config.json.
+    Without a config file a server and a key must both be given.
+    Values from flags and the environment are never saved.
+
+EXIT STATUS:
+    0  success
+    1  any other failure (e.g. some records of a bulk operation failed)
+    2  invalid flags or arguments
+    3  configuration missing or unusable
+    4  server unreachable (connection error or timeout)
+    5  server responded with an error status (4xx/5xx)
+
+For more information, see the documentation.
+`, version)
+}
+
+// checkAllowedDomain enforces the optional allowed_domains policy from the
+// config. Patterns use path.Match syntax, e.g. "*.team-a.lan".
+func checkAllowedDomain(domain string) error {
+	cfg, err := loadConfig()
+	if err != nil || len(cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	
+	domain = strings.ToLower(domain)
+	for _, pattern := range cfg.AllowedDomains {
+		if ok, _ := path.Match(strings.ToLower(pattern), domain); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %s is not permitted by allowed_domains (%s)", domain, strings.Join(cfg.AllowedDomains, ", "))
+}
+
+// requireFlags checks that a single-record command got --domain and its
+// address flag, naming whichever are missing. --resolve stands in for the
+// address.
+func requireFlags(command, domain, addr, addrFlag string) error {
+	var missing []string
+	if domain == "" {
+		missing = append(missing, "--domain")
+	}
+	if addr == "" && *optResolve == "" {
+		missing = append(missing, addrFlag)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s command requires --domain and %s (or --resolve), missing %s", command, addrFlag, joinFlags(missing))
+	}
+	return nil
+}
+
+// joinFlags lists flag names as "a", "a and b" or "a, b and c".
+func joinFlags(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+func validateArgs() error {
+	var commands []string
+	if *cmdList { commands = append(commands, "--list") }
+	if *cmdAdd { commands = append(commands, "--add") }
+	if *cmdUpdate { commands = append(commands, "--update") }
+	if *cmdUpsert { commands = append(commands, "--upsert") }
+	if *cmdDelete { commands = append(commands, "--delete") }
+	if *cmdGet { commands = append(commands, "--get") }
+	if *cmdImport != "" { commands = append(commands, "--import") }
+	if *cmdExport != "" { commands = append(commands, "--export") }
+	if *cmdQuota { commands = append(commands, "--quota") }
+	if *cmdPing { commands = append(commands, "--ping") }
+	if *cmdApply != "" { commands = append(commands, "--apply") }
+	if *cmdRotate { commands = append(commands, "--rotate-key") }
+	if *cmdStale { commands = append(commands, "--detect-stale") }
+	if *cmdREPL { commands = append(commands, "--interactive") }
+	if *cmdDiff { commands = append(commands, "--diff") }
+	
+	if len(commands) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+	if len(commands) > 1 {
+		return fmt.Errorf("multiple commands specified (%s), give only one", joinFlags(commands))
+	}
+	
+	if *optPlanDir != "" && (*cmdImport == "" || !*optDryRun) {
+		return fmt.Errorf("--plan-dir requires --import with --dry-run")
+	}
+	
+	if *optRetries < 0 || *optRetries > 10 {
+		return fmt.Errorf("--retries must be between 0 and 10")
+	}
+	
+	if *optRetryStatus != "" {
+		codes, err := parseStatusCodes(*optRetryStatus)
+		if err != nil {
+			return err
+		}
+		retryStatusCodes = codes
+	}
+	
+	if *optProbePort < 0 || *optProbePort > 65535 {
+		return fmt.Errorf("--probe-port must be between 1 and 65535")
+	}
+	if *cmdDiff != (*optOtherProfile != "") {
+		return fmt.Errorf("--diff and --other-profile must be used together")
+	}
+	
+	if *flagNoConfig && (*optConfig != "" || *optProfile != "" || *optOtherProfile != "" || *cmdRotate) {
+		return fmt.Errorf("--no-config cannot be combined with --config, --profile, --other-profile or --rotate-key")
+	}
+	
+	if *optPort < 0 || *optPort > 65535 {
+		return fmt.Errorf("--port must be between 1 and 65535")
+	}
+	
+	if *flagQuiet && *flagVerbose {
+		return fmt.Errorf("--quiet and -v are mutually exclusive")
+	}
+	// Confirmation prompts go to stderr, which --silent discards as well,
+	// and the --interactive-delete list to stdout.
+	if *optPrune && (*flagQuiet || *flagSilent) {
+		return fmt.Errorf("--interactive-delete needs its record list, drop --quiet and --silent")
+	}
+	if *flagSilent && (*cmdDelete || *cmdREPL) && !*optYes && isTerminal(os.Stdin) {
+		return fmt.Errorf("--silent hides the delete confirmation, add --yes")
+	}
+	
+	if *optAPIKey != "" && *optAPIKeyFile != "" {
+		return fmt.Errorf("--apikey and --apikey-file are mutually exclusive")
+	}
+	
+	if *optTTL < 0 {
+		return fmt.Errorf("--ttl must not be negative")
+	}
+	if *optTTL > 0 && !*cmdAdd && !*cmdUpdate && !*cmdUpsert {
+		return fmt.Errorf("--ttl is only supported with --add, --update or --upsert")
+	}
+	
+	if *optWatch && !*cmdList {
+		return fmt.Errorf("--watch is only supported with --list")
+	}
+	if *optInterval <= 0 {
+		return fmt.Errorf("--interval must be positive")
 	}
 	
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("server returned %s: %s", resp.Status, string(responseBody))
+	switch *optColor {
+	case "auto", "always", "never":
+	default:
+		return fmt.Errorf("unknown color mode %q, expected auto, always or never", *optColor)
+	}
+	
+	if *optAppend && *optOutputFile == "" {
+		return fmt.Errorf("--append requires --output-file")
+	}
+	
+	if *optPageSize < 0 {
+		return fmt.Errorf("--page-size must not be negative")
+	}
+	
+	if *optFailOnDup && !*cmdList {
+		return fmt.Errorf("--fail-on-duplicates is only supported with --list")
+	}
+	
+	if *optCount && !*cmdList {
+		return fmt.Errorf("--count is only supported with --list")
+	}
+	
+	if *optDomainsOnly && *optIPsOnly {
+		return fmt.Errorf("--domains-only and --ips-only are mutually exclusive")
+	}
+	
+	if *optFields != "" {
+		if !*cmdList || *optOutput == "json" {
+			return fmt.Errorf("--fields is only supported with --list and table or csv output")
+		}
+		fields, err := parseFields(*optFields)
+		if err != nil {
+			return err
+		}
+		listFields = fields
+	}
+	
+	if *optTemplate != "" {
+		if !*cmdList || *optOutput != "table" {
+			return fmt.Errorf("--format-template is only supported with --list and table output")
+		}
+		tmpl, err := template.New("record").Parse(*optTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid --format-template: %v", err)
+		}
+		// Executing against an empty record catches unknown fields now
+		// rather than halfway through the output.
+		if err := tmpl.Execute(io.Discard, Record{}); err != nil {
+			return fmt.Errorf("invalid --format-template: %v", err)
+		}
+		recordTemplate = tmpl
+	}
+	
+	if *optMinTLS != "" {
+		v, ok := tlsVersions[*optMinTLS]
+		if !ok {
+			return fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", *optMinTLS)
+		}
+		minTLSVersion = v
+	}
+	
+	if *optInsecure && *optCACert != "" {
+		return fmt.Errorf("--insecure and --cacert are mutually exclusive")
+	}
+	if *optCACert != "" {
+		pool, err := loadCACert(*optCACert)
+		if err != nil {
+			return err
+		}
+		caPool = pool
+	}
+	if *optInsecure {
+		fmt.Fprintf(os.Stderr, "dnscli: warning: --insecure disables TLS certificate verification\n")
+	}
+	
+	if *optStaleAfter != "" {
+		d, err := parseAge(*optStaleAfter)
+		if err != nil {
+			return err
+		}
+		staleAfter = d
+	}
+	if *optFailOnStale && *optStaleAfter == "" {
+		return fmt.Errorf("--fail-on-stale requires --stale-after")
+	}
+	
+	switch *optGroupBy {
+	case "", "group", "comment", "ip":
+	default:
+		return fmt.Errorf("cannot group by %q, expected group, comment or ip", *optGroupBy)
+	}
+	
+	switch *optSort {
+	case "", "domain", "ip":
+	default:
+		return fmt.Errorf("cannot sort by %q, expected domain or ip", *optSort)
+	}
+	
+	if *optIPFamily != 0 && *optIPFamily != 4 && *optIPFamily != 6 {
+		return fmt.Errorf("--ip-family must be 4 or 6")
+	}
+	
+	if *optProxy != "" {
+		u, err := url.Parse(*optProxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid --proxy %q, expected a URL such as http://proxy:3128", *optProxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", u.Scheme)
+		}
+		proxyURL = u
+	}
+	
+	if *optConnectTimeout < 0 {
+		return fmt.Errorf("connect timeout must not be negative")
+	}
+	if *optTimeout < 0 {
+		return fmt.Errorf("timeout must not be negative, use 0s to disable it")
+	}
+	
+	switch *optOutput {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, csv or json", *optOutput)
+	}
+	
+	if *optBatch != "" && !*cmdAdd && !*cmdDelete {
+		return fmt.Errorf("--batch is only supported with --add or --delete")
+	}
+	if *optStdin {
+		if !*cmdAdd && !*cmdUpdate {
+			return fmt.Errorf("--stdin is only supported with --add or --update")
+		}
+		if *optDomain != "" || *optIP != "" || *optNewIP != "" || *optBatch != "" || *optResolve != "" {
+			return fmt.Errorf("--stdin takes the records from standard input, not from --domain, --ip, --new-ip, --resolve or --batch")
+		}
+	}
+	if *optConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	if *optRate < 0 {
+		return fmt.Errorf("--rate must not be negative")
+	}
+	if *optRate > float64(time.Second) {
+		return fmt.Errorf("--rate must be at most %d requests per second", time.Second)
+	}
+	if *optSummaryJSON && *optBatch == "" {
+		return fmt.Errorf("--summary-json is only supported with --batch")
+	}
+	if *optRate > 0 && *optBatch == "" {
+		return fmt.Errorf("--rate is only supported with --batch")
+	}
+	// --check-dns verifies the single record named by --domain.
+	if *optCheckDNS && (*optBatch != "" || *optStdin) {
+		return fmt.Errorf("--check-dns is not supported with --batch or --stdin")
+	}
+	
+	if *optResolve != "" {
+		if !(*cmdAdd || *cmdUpdate || *cmdUpsert) || *optBatch != "" {
+			return fmt.Errorf("--resolve is only supported with --add, --update or --upsert")
+		}
+		if (*cmdUpdate && *optNewIP != "") || (!*cmdUpdate && *optIP != "") {
+			return fmt.Errorf("--resolve replaces the address, do not give --ip or --new-ip as well")
+		}
+		if *optType != "" && *optType != "A" && *optType != "AAAA" {
+			return fmt.Errorf("--resolve only works for A and AAAA records")
+		}
+	}
+	if *optPreferV4 && *optPreferV6 {
+		return fmt.Errorf("--prefer-ipv4 and --prefer-ipv6 are mutually exclusive")
+	}
+	if (*optPreferV4 || *optPreferV6) && *optResolve == "" {
+		return fmt.Errorf("--prefer-ipv4 and --prefer-ipv6 are only supported with --resolve")
+	}
+	
+	if *cmdAdd && *optBatch == "" && !*optStdin {
+		if err := requireFlags("add", *optDomain, *optIP, "--ip"); err != nil {
+			return err
+		}
+	}
+	
+	if *cmdUpsert {
+		if err := requireFlags("upsert", *optDomain, *optIP, "--ip"); err != nil {
+			return err
+		}
+	}
+	
+	if *cmdUpdate && !*optStdin {
+		if err := requireFlags("update", *optDomain, *optNewIP, "--new-ip"); err != nil {
+			return err
+		}
+	}
+	if *optIfCurrent != "" {
+		if !*cmdUpdate {
+			return fmt.Errorf("--if-current is only supported with --update")
+		}
+		ip, err := canonicalIP("--if-current", *optIfCurrent)
+		if err != nil {
+			return err
+		}
+		*optIfCurrent = ip
+	}
+	
+	if *optMatch != "" && (!*cmdDelete || *optDomain != "" || *optBatch != "") {
+		return fmt.Errorf("--match is only supported with --delete, without --domain or --batch")
+	}
+	for _, pattern := range []string{*optMatch, *optFilter} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	
+	if *optIgnoreMiss && (!*cmdDelete || *optBatch != "" || *optMatch != "") {
+		return fmt.Errorf("--ignore-missing is only supported with --delete --domain")
+	}
+	
+	if *cmdDelete && *optBatch == "" && *optMatch == "" {
+		if *optDomain == "" {
+			return fmt.Errorf("delete command requires --domain")
+		}
+	}
+	
+	if *cmdGet {
+		if *optDomain == "" {
+			return fmt.Errorf("get command requires --domain")
+		}
+	}
+	
+	if *optClip && !*cmdGet {
+		return fmt.Errorf("--clip is only supported with --get")
+	}
+	
+	if *optDomain != "" && (*cmdAdd || *cmdUpdate || *cmdUpsert || *cmdDelete) {
+		if err := validateDomain(*optDomain); err != nil {
+			return err
+		}
+		if err := checkAllowedDomain(*optDomain); err != nil {
+			return err
+		}
+	}
+	
+	*optType = strings.ToUpper(*optType)
+	if *optType != "" && !*cmdAdd && !*cmdUpdate && !*cmdUpsert {
+		return fmt.Errorf("--type is only supported with --add, --update or --upsert")
+	}
+	
+	var err error
+	if *optIP, err = recordValue("--ip", *optType, *optIP); err != nil {
+		return err
+	}
+	if *optNewIP, err = recordValue("--new-ip", *optType, *optNewIP); err != nil {
+		return err
+	}
+	
+	if *optType == "PTR" {
+		if *cmdUpdate {
+			return fmt.Errorf("--type PTR is not supported with --update, use --upsert")
+		}
+		// The record is named after the address and points at the host,
+		// so the two flags swap roles in the request.
+		*optDomain, *optIP = reverseName(net.ParseIP(*optIP)), *optDomain
 	}
 	
-	formatOutput(responseBody, isListCommand)
 	return nil
 }
 
-func showUsage() {
-	fmt.Printf(`dnscli - DNS record management client %s
-
-USAGE:
-    dnscli [OPTIONS] COMMAND [ARGS]
-
-OPTIONS:
-    -h, --help      show this help message
-    -v, --verbose   enable verbose output
-    --version       show version information
-    --setup         configure server endpoint and credentials
-
-COMMANDS:
-    --list                                  list all DNS records
-    --add --domain <name> --ip <addr>       add new DNS record
-    --update --domain <name> [--ip <old>] --new-ip <addr>
-                                            update existing DNS record
-    --delete --domain <name> [--ip <addr>]  delete DNS record
-
-EXAMPLES:
-    dnscli --setup
-    dnscli --list
-    dnscli --add --domain api.example.com --ip 192.168.1.100
-    dnscli --update --domain api.example.com --new-ip 192.168.1.101
-    dnscli --delete --domain api.example.com
+// reverseName returns the PTR lookup name of ip, under in-addr.arpa for IPv4
+// and ip6.arpa, one label per nibble, for IPv6.
+func reverseName(ip net.IP) string {
+	var labels []string
+	if v4 := ip.To4(); v4 != nil {
+		for i := len(v4) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(v4[i])))
+		}
+		return strings.Join(labels, ".") + ".in-addr.arpa"
+	}
+	const hexDigits = "0123456789abcdef"
+	for i := len(ip) - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip[i]&0xf]), string(hexDigits[ip[i]>>4]))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa"
+}
 
-For more information, see the documentation.
-`, version)
+// recordType returns the type of r, deriving A or AAAA from the address
+// when the server did not report one.
+func recordType(r Record) string {
+	if r.Type != "" {
+		return strings.ToUpper(r.Type)
+	}
+	if ip := net.ParseIP(r.IP); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+	return "A"
 }
 
-func validateArgs() error {
-	commands := 0
-	if *cmdList { commands++ }
-	if *cmdAdd { commands++ }
-	if *cmdUpdate { commands++ }
-	if *cmdDelete { commands++ }
+// recordValue validates the value given in flag name for a record of type
+// recType: an address for A and AAAA (canonicalized), a domain name for
+// CNAME, any text for TXT and the address to reverse for PTR. Without a
+// type any IPv4 or IPv6 address is accepted.
+func recordValue(name, recType, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
 	
-	if commands == 0 {
-		return fmt.Errorf("no command specified")
+	switch recType {
+	case "":
+		return canonicalIP(name, value)
+	case "A", "AAAA":
+		v, err := canonicalIP(name, value)
+		if err != nil {
+			return "", err
+		}
+		if isV4 := net.ParseIP(v).To4() != nil; isV4 != (recType == "A") {
+			return "", fmt.Errorf("%s: %s is not a valid address for an %s record", name, v, recType)
+		}
+		return v, nil
+	case "CNAME":
+		target := strings.TrimSuffix(value, ".")
+		if err := validateDomain(target); err != nil {
+			return "", fmt.Errorf("%s: CNAME target: %v", name, err)
+		}
+		return target, nil
+	case "TXT":
+		return value, nil
+	case "PTR":
+		return canonicalIP(name, value)
+	}
+	return "", fmt.Errorf("unknown record type %q, expected A, AAAA, CNAME, TXT or PTR", recType)
+}
+
+// validateDomain applies RFC 1035 style rules to a domain name: at most 253
+// characters, labels of 1 to 63 letters, digits or hyphens that neither start
+// nor end with a hyphen. A single leading "*." is allowed for dnsmasq
+// wildcard entries.
+func validateDomain(domain string) error {
+	name := strings.TrimPrefix(domain, "*.")
+	if name == "" {
+		return fmt.Errorf("invalid domain %q: empty name", domain)
 	}
-	if commands > 1 {
-		return fmt.Errorf("multiple commands specified")
+	if len(name) > 253 {
+		return fmt.Errorf("invalid domain %q: longer than 253 characters", domain)
 	}
 	
-	if *cmdAdd {
-		if *optDomain == "" || *optIP == "" {
-			return fmt.Errorf("add command requires --domain and --ip")
+	for _, label := range strings.Split(name, ".") {
+		switch {
+		case label == "":
+			return fmt.Errorf("invalid domain %q: empty label (check for leading, trailing or doubled dots)", domain)
+		case len(label) > 63:
+			return fmt.Errorf("invalid domain %q: label %q is longer than 63 characters", domain, label)
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("invalid domain %q: label %q starts or ends with a hyphen", domain, label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("invalid domain %q: label %q contains %q", domain, label, c)
+			}
 		}
 	}
+	return nil
+}
+
+// canonicalIP checks that value is an IPv4 or IPv6 address and returns it in
+// canonical form, so the server always sees e.g. compressed IPv6 notation.
+// An empty value is left alone.
+func canonicalIP(name, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return "", fmt.Errorf("%s: %q is not a valid IPv4 or IPv6 address", name, value)
+	}
+	return ip.String(), nil
+}
+
+// printVersion prints the client version and, when a server is configured,
+// the version reported by its /version endpoint. The server is only
+// consulted on a best-effort basis; failing to reach it is not an error.
+func printVersion() {
+	fmt.Printf("dnscli version %s\n", version)
 	
-	if *cmdUpdate {
-		if *optDomain == "" || *optNewIP == "" {
-			return fmt.Errorf("update command requires --domain and --new-ip")
-		}
+	cfg, err := loadConfig()
+	if err != nil {
+		return
+	}
+	var result struct {
+		Version string `json:"version"`
+	}
+	if err := fetchJSON(cfg, "/version", &result); err != nil || result.Version == "" {
+		fmt.Printf("server version unknown (%s)\n", cfg.Server)
+		return
+	}
+	fmt.Printf("server version %s (%s)\n", result.Version, cfg.Server)
+	if result.Version != version {
+		fmt.Println("Warning: client and server versions differ")
+	}
+}
+
+// printError reports a failed command: as a JSON object on stdout with
+// --output json, otherwise on stderr.
+func printError(err error) {
+	if *optOutput == "json" && !*flagQuiet {
+		printJSON(newJSONError(err))
+	} else if colorEnabled(os.Stderr) {
+		fmt.Fprintf(os.Stderr, "%sdnscli: %v%s\n", colorRed, err, colorReset)
+	} else {
+		fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+	}
+}
+
+// resolveAddress looks up host for --resolve and picks the address to use:
+// the first one of the family required by --type or preferred with
+// --prefer-ipv4/--prefer-ipv6, else the first one returned.
+func resolveAddress(host string) (string, error) {
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return "", &networkError{fmt.Errorf("cannot resolve %s: %v", host, err)}
 	}
 	
-	if *cmdDelete {
-		if *optDomain == "" {
-			return fmt.Errorf("delete command requires --domain")
+	wantV4, wantV6 := *optPreferV4 || *optType == "A", *optPreferV6 || *optType == "AAAA"
+	chosen := ""
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			continue
+		}
+		isV4 := ip.To4() != nil
+		if (wantV4 && isV4) || (wantV6 && !isV4) {
+			chosen = a
+			break
+		}
+		if chosen == "" && *optType == "" {
+			chosen = a
 		}
 	}
-	
-	return nil
+	if chosen == "" {
+		return "", fmt.Errorf("%s has no suitable address", host)
+	}
+	return canonicalIP("--resolve", chosen)
 }
 
 func main() {
 	flag.Parse()
 	
+	if *flagJSON {
+		*optOutput = "json"
+	}
+	
+	if *flagSilent {
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout = devNull
+			os.Stderr = devNull
+		}
+	}
+	
 	if *flagHelp {
 		showUsage()
 		return
 	}
 	
 	if *flagVersion {
-		fmt.Printf("dnscli version %s\n", version)
+		printVersion()
+		return
+	}
+	
+	if *flagCompletion != "" {
+		if err := printCompletion(*flagCompletion); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		return
+	}
+	
+	if flagGiven("complete-domains") {
+		completeDomains(*flagCompDomains)
+		return
+	}
+	
+	if *flagNoConfig && (*flagSetup || *flagCheckConfig) {
+		fmt.Fprintf(os.Stderr, "dnscli: --setup and --check-config need the config file, drop --no-config\n")
+		os.Exit(exitUsage)
+	}
+	
+	if *flagCheckConfig {
+		if err := checkConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+			os.Exit(exitCode(err))
+		}
 		return
 	}
 	
 	if *flagSetup {
 		if err := setupConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		return
 	}
 	
+	*optDomain = qualifyDomain(*optDomain)
+	
 	if err := validateArgs(); err != nil {
 		fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Try 'dnscli --help' for more information.\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+	
+	if *flagQuiet {
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			summaryOut = os.Stdout
+			os.Stdout = devNull
+		}
+	}
+	
+	if *optLogFile != "" {
+		if err := openRequestLog(*optLogFile); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	}
+	
+	closeOutput := func() {}
+	if *optOutputFile != "" {
+		finish, err := teeStdout(*optOutputFile, *optAppend)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		closeOutput = finish
+	}
+	
+	if *optResolve != "" {
+		ip, err := resolveAddress(*optResolve)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
+			closeOutput()
+			os.Exit(exitCode(err))
+		}
+		fmt.Fprintf(os.Stderr, "dnscli: %s resolves to %s\n", *optResolve, ip)
+		if *cmdUpdate {
+			*optNewIP = ip
+		} else {
+			*optIP = ip
+		}
 	}
 	
 	var err error
 	
 	switch {
+	case *cmdList && *optPrune:
+		err = runInteractiveDelete()
+		
+	case *cmdList && *optWatch:
+		err = watchList(*optInterval)
+		
 	case *cmdList:
 		err = makeRequest("GET", "/dns", nil)
 		
+	case (*cmdAdd || *cmdUpdate) && *optStdin:
+		err = runStdinRecords()
+		
+	case (*cmdAdd || *cmdDelete) && *optBatch != "":
+		err = runBatch(*optBatch)
+		
+	case *cmdDelete && *optMatch != "":
+		err = runMatchDelete(*optMatch)
+		
 	case *cmdAdd:
-		payload := Record{Domain: *optDomain, IP: *optIP}
+		if !*optMerge {
+			if err = checkExistingDomain(*optDomain, *optIP); err != nil {
+				break
+			}
+		}
+		warnQuota(1)
+		payload := Record{Domain: *optDomain, IP: *optIP, TTL: *optTTL, Type: *optType}
 		err = makeRequest("POST", "/dns", payload)
 		
+	case *cmdUpsert:
+		err = upsertRecord(Record{Domain: *optDomain, IP: *optIP, TTL: *optTTL, Type: *optType})
+		
+	case *cmdUpdate && *optPatch:
+		if err = checkCurrentIP(*optDomain, *optIP, *optIfCurrent); err != nil {
+			break
+		}
+		payload := map[string]interface{}{"domain": *optDomain, "new_ip": *optNewIP}
+		if *optIP != "" {
+			payload["ip"] = *optIP
+		}
+		if *optIfCurrent != "" {
+			payload["current_ip"] = *optIfCurrent
+		}
+		if *optTTL > 0 {
+			payload["ttl"] = *optTTL
+		}
+		if *optType != "" {
+			payload["type"] = *optType
+		}
+		err = makeRequest("PATCH", "/dns", payload)
+		
 	case *cmdUpdate:
-		payload := Record{Domain: *optDomain, IP: *optIP, NewIP: *optNewIP}
+		if err = checkCurrentIP(*optDomain, *optIP, *optIfCurrent); err != nil {
+			break
+		}
+		payload := Record{Domain: *optDomain, IP: *optIP, NewIP: *optNewIP, TTL: *optTTL, Type: *optType, CurrentIP: *optIfCurrent}
 		err = makeRequest("PUT", "/dns", payload)
 		
 	case *cmdDelete:
+		if !*optYes && !*optDryRun && isTerminal(os.Stdin) {
+			target := *optDomain + " (all addresses)"
+			if *optIP != "" {
+				target = *optDomain + " -> " + *optIP
+			}
+			if !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %s? [y/N]: ", target)) {
+				fmt.Println("Nothing deleted")
+				break
+			}
+		}
 		payload := Record{Domain: *optDomain, IP: *optIP}
 		err = makeRequest("DELETE", "/dns", payload)
+		if *optIgnoreMiss && isNotFound(err) {
+			err = reportAbsent(*optDomain)
+		}
+		
+	case *cmdGet:
+		err = getRecord(*optDomain)
+		
+	case *cmdImport != "":
+		err = runImport(*cmdImport)
+		
+	case *cmdExport != "":
+		err = runExport(*cmdExport)
+		
+	case *cmdPing:
+		err = ping()
+		
+	case *cmdQuota:
+		err = showQuota()
+		
+	case *cmdApply != "":
+		err = applyPlaybook(*cmdApply)
+		
+	case *cmdRotate:
+		err = rotateKey(*optNewKey)
+		
+	case *cmdStale:
+		err = detectStale()
+		
+	case *cmdREPL:
+		err = runREPL()
+		
+	case *cmdDiff:
+		err = runDiff(*optOtherProfile)
+	}
+	
+	if err == nil && *optCheckDNS && !*optDryRun && (*optType == "" || *optType == "A" || *optType == "AAAA") {
+		switch {
+		case *cmdAdd, *cmdUpsert:
+			err = checkDNS(*optDomain, *optIP)
+		case *cmdUpdate:
+			err = checkDNS(*optDomain, *optNewIP)
+		}
 	}
 	
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "dnscli: %v\n", err)
-		os.Exit(1)
+		printError(err)
+		closeOutput()
+		os.Exit(exitCode(err))
 	}
+	closeOutput()
 }
\ No newline at end of file
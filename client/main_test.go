@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncatedConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := "{\n  \"default\": \"router\",\n  \"profiles\": {\n    \"router\": {\"server\": \"http://192.168.1.1:8080\""
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DNSCLI_CONFIG", path)
+	t.Setenv("DNSCLI_SERVER", "")
+	t.Setenv("DNSCLI_APIKEY", "")
+
+	_, err := readConfigFile()
+	if err == nil {
+		t.Fatal("readConfigFile accepted a truncated file")
+	}
+	if msg := err.Error(); !strings.Contains(msg, path) || !strings.Contains(msg, "line 4") {
+		t.Errorf("readConfigFile error %q does not name %s and line 4", msg, path)
+	}
+
+	_, err = loadConfig()
+	if err == nil {
+		t.Fatal("loadConfig accepted a truncated file")
+	}
+	msg := configError(err).Error()
+	if !strings.Contains(msg, "is malformed") || !strings.Contains(msg, path) {
+		t.Errorf("loadConfig error %q does not report the malformed file", msg)
+	}
+	if strings.Contains(msg, "run 'dnscli -setup' first") {
+		t.Errorf("loadConfig error %q reports the file as missing", msg)
+	}
+}
+
+// setArgs resets every dnscli flag to its default and parses args, as if
+// they were the command line.
+func setArgs(t *testing.T, args ...string) {
+	t.Helper()
+	reset := func() {
+		flag.VisitAll(func(f *flag.Flag) {
+			if !strings.HasPrefix(f.Name, "test.") {
+				f.Value.Set(f.DefValue)
+			}
+		})
+	}
+	reset()
+	t.Cleanup(reset)
+	if err := flag.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateArgsMessages(t *testing.T) {
+	t.Setenv("DNSCLI_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "no command specified"},
+		{[]string{"--add", "--delete"}, "multiple commands specified (--add and --delete), give only one"},
+		{[]string{"--list", "--get", "--ping"}, "multiple commands specified (--list, --get and --ping), give only one"},
+		{[]string{"--add", "--ip", "10.0.0.1"}, "add command requires --domain and --ip (or --resolve), missing --domain"},
+		{[]string{"--add", "--domain", "a.lan"}, "add command requires --domain and --ip (or --resolve), missing --ip"},
+		{[]string{"--add"}, "add command requires --domain and --ip (or --resolve), missing --domain and --ip"},
+		{[]string{"--update", "--new-ip", "10.0.0.1"}, "update command requires --domain and --new-ip (or --resolve), missing --domain"},
+		{[]string{"--update", "--domain", "a.lan"}, "update command requires --domain and --new-ip (or --resolve), missing --new-ip"},
+		{[]string{"--update"}, "update command requires --domain and --new-ip (or --resolve), missing --domain and --new-ip"},
+		{[]string{"--upsert", "--ip", "10.0.0.1"}, "upsert command requires --domain and --ip (or --resolve), missing --domain"},
+		{[]string{"--upsert", "--domain", "a.lan"}, "upsert command requires --domain and --ip (or --resolve), missing --ip"},
+		{[]string{"--upsert"}, "upsert command requires --domain and --ip (or --resolve), missing --domain and --ip"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			setArgs(t, tt.args...)
+			err := validateArgs()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("validateArgs() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireFlags(t *testing.T) {
+	setArgs(t)
+	if err := requireFlags("add", "a.lan", "10.0.0.1", "--ip"); err != nil {
+		t.Errorf("requireFlags with both flags = %v, want nil", err)
+	}
+
+	setArgs(t, "--resolve", "nas.example.com")
+	if err := requireFlags("add", "a.lan", "", "--ip"); err != nil {
+		t.Errorf("requireFlags with --resolve = %v, want nil", err)
+	}
+	want := "add command requires --domain and --ip (or --resolve), missing --domain"
+	if err := requireFlags("add", "", "", "--ip"); err == nil || err.Error() != want {
+		t.Errorf("requireFlags with --resolve and no domain = %v, want %q", err, want)
+	}
+}
+
+func TestJoinFlags(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"--add"}, "--add"},
+		{[]string{"--add", "--delete"}, "--add and --delete"},
+		{[]string{"--list", "--get", "--ping"}, "--list, --get and --ping"},
+	}
+	for _, tt := range tests {
+		if got := joinFlags(tt.names); got != tt.want {
+			t.Errorf("joinFlags(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestUnquoteYAML(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`api.lan`, "api.lan"},
+		{`api.lan   # note`, "api.lan"},
+		{`"api.lan"`, "api.lan"},
+		{`"api.lan"   # note`, "api.lan"},
+		{`'api.lan' # note`, "api.lan"},
+		{`"a # b"`, "a # b"},
+	}
+	for _, tt := range tests {
+		if got := unquoteYAML(tt.in); got != tt.want {
+			t.Errorf("unquoteYAML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 500 * time.Millisecond},
+		{1, time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ping checks that the server is reachable and accepts the API key by
+// listing the records, and reports the round-trip time.
+func ping() error {
+	start := time.Now()
+	resp, body, err := apiRequest(context.Background(), "GET", "/dns", nil)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	var netErr *networkError
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("server unreachable: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("server reachable (%s) but the API key was rejected: %w", latency, statusError(resp, body))
+	}
+	if err := statusError(resp, body); err != nil {
+		return err
+	}
+
+	if *optOutput == "json" {
+		return printJSON(map[string]interface{}{
+			"status":     resp.StatusCode,
+			"latency_ms": latency.Milliseconds(),
+		})
+	}
+	fmt.Printf("OK (%s)\n", latency)
+	return nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A playbook is a YAML file of ordered steps applied with --apply:
+//
+//	steps:
+//	  - action: ensure      # add, update, delete or ensure
+//	    domain: api.lan
+//	    ip: 192.168.1.10
+//	  - action: delete
+//	    domain: old.lan
+//	    when: exists        # optional: exists or absent
+//
+// ensure adds the record, or updates the domain to ip if it points elsewhere,
+// and does nothing when it is already correct. update takes the current
+// address in ip (optional) and the new one in new_ip. Steps whose when
+// condition does not hold for the domain are skipped.
+//
+// Only this subset of YAML is understood: a top-level "steps" list of flat
+// key/value mappings, with comments and optionally quoted scalars.
+type playbookStep struct {
+	Line   int
+	Action string
+	Domain string
+	IP     string
+	NewIP  string
+	When   string
+}
+
+// unquoteYAML returns a scalar value without its quotes and without a
+// trailing comment. A " #" inside quotes is part of the value.
+func unquoteYAML(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]) + 1; end > 0 {
+			rest := strings.TrimSpace(value[end+1:])
+			if rest == "" || rest[0] == '#' {
+				return value[1:end]
+			}
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+func parsePlaybook(r io.Reader) ([]playbookStep, error) {
+	var steps []playbookStep
+	inSteps := false
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			if trimmed != "steps:" {
+				return nil, fmt.Errorf("line %d: expected top-level \"steps:\"", n)
+			}
+			inSteps = true
+			continue
+		}
+		if !inSteps {
+			return nil, fmt.Errorf("line %d: entry outside of \"steps:\"", n)
+		}
+
+		if strings.HasPrefix(trimmed, "-") {
+			steps = append(steps, playbookStep{Line: n})
+			trimmed = strings.TrimSpace(trimmed[1:])
+			if trimmed == "" {
+				continue
+			}
+		}
+		if len(steps) == 0 {
+			return nil, fmt.Errorf("line %d: expected a list item", n)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		step := &steps[len(steps)-1]
+		value = unquoteYAML(value)
+		switch strings.TrimSpace(key) {
+		case "action":
+			step.Action = value
+		case "domain":
+			step.Domain = value
+		case "ip":
+			step.IP = value
+		case "new_ip":
+			step.NewIP = value
+		case "when":
+			step.When = value
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", n, strings.TrimSpace(key))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range steps {
+		if err := validateStep(&steps[i]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", steps[i].Line, err)
+		}
+	}
+	return steps, nil
+}
+
+func validateStep(s *playbookStep) error {
+	if s.Domain == "" {
+		return fmt.Errorf("step requires domain")
+	}
+	s.Domain = qualifyDomain(s.Domain)
+	if err := validateDomain(s.Domain); err != nil {
+		return err
+	}
+	var err error
+	if s.IP, err = canonicalIP("ip", s.IP); err != nil {
+		return err
+	}
+	if s.NewIP, err = canonicalIP("new_ip", s.NewIP); err != nil {
+		return err
+	}
+
+	switch s.Action {
+	case "add", "ensure":
+		if s.IP == "" {
+			return fmt.Errorf("%s requires ip", s.Action)
+		}
+	case "update":
+		if s.NewIP == "" {
+			return fmt.Errorf("update requires new_ip")
+		}
+	case "delete":
+	default:
+		return fmt.Errorf("unknown action %q", s.Action)
+	}
+
+	switch s.When {
+	case "", "exists", "absent":
+	default:
+		return fmt.Errorf("unknown condition %q, expected exists or absent", s.When)
+	}
+	return checkAllowedDomain(s.Domain)
+}
+
+// playbookState tracks the records as the playbook is applied so conditions
+// of later steps see the effect of earlier ones.
+type playbookState []Record
+
+func (st playbookState) ips(domain string) []string {
+	var ips []string
+	for _, r := range st {
+		if r.Domain == domain {
+			ips = append(ips, r.IP)
+		}
+	}
+	return ips
+}
+
+func (st playbookState) without(domain, ip string) playbookState {
+	var kept playbookState
+	for _, r := range st {
+		if r.Domain != domain || (ip != "" && r.IP != ip) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// planStep resolves a step against the current state into the request to
+// send. A nil payload means there is nothing to do, with the reason given.
+func planStep(s playbookStep, st playbookState) (method string, payload *Record, reason string) {
+	ips := st.ips(s.Domain)
+	if s.When == "exists" && len(ips) == 0 {
+		return "", nil, "skipped, domain absent"
+	}
+	if s.When == "absent" && len(ips) > 0 {
+		return "", nil, "skipped, domain exists"
+	}
+
+	switch s.Action {
+	case "add":
+		return "POST", &Record{Domain: s.Domain, IP: s.IP}, ""
+	case "update":
+		return "PUT", &Record{Domain: s.Domain, IP: s.IP, NewIP: s.NewIP}, ""
+	case "delete":
+		return "DELETE", &Record{Domain: s.Domain, IP: s.IP}, ""
+	}
+
+	// ensure
+	if len(ips) == 1 && ips[0] == s.IP {
+		return "", nil, "already up to date"
+	}
+	if len(ips) > 0 {
+		return "PUT", &Record{Domain: s.Domain, NewIP: s.IP}, ""
+	}
+	return "POST", &Record{Domain: s.Domain, IP: s.IP}, ""
+}
+
+func applyPlaybook(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	steps, err := parsePlaybook(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch existing records: %w", err)
+	}
+	state := playbookState(records)
+
+	failed := 0
+	for i, s := range steps {
+		fmt.Printf("[%d/%d] %s %s: ", i+1, len(steps), s.Action, s.Domain)
+
+		method, payload, reason := planStep(s, state)
+		if payload == nil {
+			fmt.Println(reason)
+			continue
+		}
+		if *optDryRun {
+			switch method {
+			case "POST":
+				fmt.Printf("would add %s -> %s\n", payload.Domain, payload.IP)
+			case "PUT":
+				fmt.Printf("would update %s -> %s\n", payload.Domain, payload.NewIP)
+			case "DELETE":
+				fmt.Printf("would delete %s\n", payload.Domain)
+			}
+		} else if err := makeRequest(method, "/dns", *payload); err != nil {
+			fmt.Printf("failed: %v\n", err)
+			failed++
+			if *optAbortOnError {
+				return fmt.Errorf("aborted at step %d of %d, earlier steps were applied", i+1, len(steps))
+			}
+			continue
+		}
+
+		switch method {
+		case "POST":
+			state = append(state, Record{Domain: payload.Domain, IP: payload.IP})
+		case "PUT":
+			state = append(state.without(payload.Domain, payload.IP), Record{Domain: payload.Domain, IP: payload.NewIP})
+		case "DELETE":
+			state = state.without(payload.Domain, payload.IP)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(steps))
+	}
+	return nil
+}
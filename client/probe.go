@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// probeRecord checks whether the host behind a record is alive. With a port
+// it must accept a TCP connection; otherwise a single ICMP echo via the
+// system ping command is used.
+func probeRecord(r Record, port int, timeout time.Duration) error {
+	if port > 0 {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(r.IP, strconv.Itoa(port)), timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	args := []string{"-c", "1", "-W", strconv.Itoa(int(timeout.Seconds() + 0.5))}
+	if runtime.GOOS == "windows" {
+		args = []string{"-n", "1", "-w", strconv.Itoa(int(timeout.Milliseconds()))}
+	}
+	if net.ParseIP(r.IP).To4() == nil && runtime.GOOS != "windows" {
+		args = append([]string{"-6"}, args...)
+	}
+	if err := exec.Command("ping", append(args, r.IP)...).Run(); err != nil {
+		return fmt.Errorf("no reply")
+	}
+	return nil
+}
+
+// detectStale probes the records concurrently and prints an audit table.
+// It fails if any record is unreachable so it can be used in monitoring.
+func detectStale() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	// At most --concurrency probes, and so ping processes, run at once.
+	results := make([]error, len(records))
+	sem := make(chan struct{}, *optConcurrency)
+	var wg sync.WaitGroup
+	for i, r := range records {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, r Record) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = probeRecord(r, *optProbePort, *optProbeTimeout)
+		}(i, r)
+	}
+	wg.Wait()
+
+	probe := "ping"
+	if *optProbePort > 0 {
+		probe = "tcp/" + strconv.Itoa(*optProbePort)
+	}
+
+	stale := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "DOMAIN\tIP ADDRESS\tPROBE\tSTATUS\n")
+	for i, r := range records {
+		status := "ok"
+		if results[i] != nil {
+			status = "STALE (" + results[i].Error() + ")"
+			stale++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Domain, r.IP, probe, status)
+	}
+	w.Flush()
+
+	fmt.Printf("\nTotal: %d records, %d stale\n", len(records), stale)
+	if stale > 0 {
+		return fmt.Errorf("%d records did not respond", stale)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// progress periodically reports throughput of a bulk operation on stderr.
+// A nil *progress is valid and does nothing, so callers need not check
+// whether reporting is enabled.
+type progress struct {
+	total int
+	done  int64
+	start time.Time
+	stop  chan struct{}
+}
+
+// isTerminal reports whether f is a character device other than the null
+// device, which is close enough to a TTY check without extra dependencies.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
+
+func startProgress(total int, interval time.Duration) *progress {
+	if interval <= 0 || total == 0 || *flagQuiet || !isTerminal(os.Stderr) {
+		return nil
+	}
+
+	p := &progress{
+		total: total,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *progress) report() {
+	done := atomic.LoadInt64(&p.done)
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	rate := float64(done) / elapsed
+	eta := "unknown"
+	if rate > 0 {
+		remaining := time.Duration(float64(int64(p.total)-done)/rate) * time.Second
+		eta = remaining.Round(time.Second).String()
+	}
+	fmt.Fprintf(os.Stderr, "processed %d/%d (%.0f rec/s, ETA %s)\n", done, p.total, rate, eta)
+}
+
+func (p *progress) increment() {
+	if p != nil {
+		atomic.AddInt64(&p.done, 1)
+	}
+}
+
+func (p *progress) finish() {
+	if p != nil {
+		close(p.stop)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// parseSelection turns input such as "1 3,5-7" into zero-based indexes into
+// a list of n items, rejecting anything out of range.
+func parseSelection(input string, n int) ([]int, error) {
+	var selected []int
+	seen := make(map[int]bool)
+
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, field := range fields {
+		lo, hi := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			lo, hi = field[:i], field[i+1:]
+		}
+
+		start, err1 := strconv.Atoi(lo)
+		end, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || start > end {
+			return nil, fmt.Errorf("invalid selection %q", field)
+		}
+		if start < 1 || end > n {
+			return nil, fmt.Errorf("selection %q is out of range 1-%d", field, n)
+		}
+
+		for i := start; i <= end; i++ {
+			if !seen[i] {
+				seen[i] = true
+				selected = append(selected, i-1)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// confirm prints prompt and reports whether the answer was yes. The prompt
+// goes to stderr so that it is still shown when --quiet discards stdout.
+func confirm(reader *bufio.Reader, prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	answer, _ := reader.ReadString('\n')
+	a := strings.ToLower(strings.TrimSpace(answer))
+	return a == "y" || a == "yes"
+}
+
+// runInteractiveDelete lists the records with numbers, reads which ones to
+// remove from stdin and deletes them after confirmation.
+func runInteractiveDelete() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No DNS records found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "#\tDOMAIN\tIP ADDRESS\n")
+	for i, r := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, r.Domain, r.IP)
+	}
+	w.Flush()
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("\nRecords to delete (e.g. 1 3 5-7, empty to cancel): ")
+	input, _ := reader.ReadString('\n')
+	if strings.TrimSpace(input) == "" {
+		fmt.Println("Nothing deleted")
+		return nil
+	}
+
+	selected, err := parseSelection(input, len(records))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	chosen := make([]Record, len(selected))
+	for n, i := range selected {
+		chosen[n] = records[i]
+		fmt.Printf("  %s -> %s\n", records[i].Domain, records[i].IP)
+	}
+	if err := checkAllowedRecords(chosen); err != nil {
+		return err
+	}
+	if !confirm(reader, fmt.Sprintf("Delete %d records? [y/N]: ", len(selected))) {
+		fmt.Println("Nothing deleted")
+		return nil
+	}
+
+	failed := 0
+	for n, r := range chosen {
+		if err := makeRequest("DELETE", "/dns", Record{Domain: r.Domain, IP: r.IP}); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %s: %v\n", r.Domain, err)
+			failed++
+			if *optAbortOnError {
+				return fmt.Errorf("aborted after %d of %d deletions", n, len(selected))
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deletions failed", failed, len(selected))
+	}
+	return nil
+}
+
+// checkAllowedRecords applies checkAllowedDomain to every record before any
+// of them is deleted, so that a policy violation leaves them all in place.
+func checkAllowedRecords(records []Record) error {
+	for _, r := range records {
+		if err := checkAllowedDomain(r.Domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMatchDelete deletes every record whose domain matches pattern, using
+// the same matching as --list --filter. The matches are listed first and
+// deletion needs --yes or a confirmation on the terminal.
+func runMatchDelete(pattern string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	records, err := fetchRecords(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	var matched []Record
+	for _, r := range records {
+		if domainMatches(pattern, r.Domain) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		fmt.Printf("No records match %q\n", pattern)
+		return nil
+	}
+
+	for _, r := range matched {
+		fmt.Printf("  %s -> %s\n", r.Domain, r.IP)
+	}
+	if err := checkAllowedRecords(matched); err != nil {
+		return err
+	}
+	if !*optYes && !*optDryRun {
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("refusing to delete %d records without --yes", len(matched))
+		}
+		if !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %d records? [y/N]: ", len(matched))) {
+			fmt.Println("Nothing deleted")
+			return nil
+		}
+	}
+
+	deleted, failed := 0, 0
+	for n, r := range matched {
+		if err := makeRequest("DELETE", "/dns", Record{Domain: r.Domain, IP: r.IP}); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: %s: %v\n", r.Domain, err)
+			failed++
+			if *optAbortOnError {
+				return fmt.Errorf("aborted after %d of %d deletions", n, len(matched))
+			}
+			continue
+		}
+		deleted++
+	}
+
+	if *optDryRun {
+		fmt.Printf("\nWould delete %d of %d matching records (dry run)\n", deleted, len(matched))
+	} else {
+		fmt.Printf("\nDeleted %d of %d matching records\n", deleted, len(matched))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deletions failed", failed, len(matched))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// quotaWarnRatio is the fraction of the record limit at which add and import
+// start warning about remaining capacity.
+const quotaWarnRatio = 0.9
+
+type Quota struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+func fetchQuota(cfg Config) (Quota, error) {
+	var q Quota
+	err := fetchJSON(cfg, "/quota", &q)
+	return q, err
+}
+
+func showQuota() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+
+	q, err := fetchQuota(cfg)
+	if err != nil {
+		return fmt.Errorf("server does not report quota information: %w", err)
+	}
+
+	if q.Limit <= 0 {
+		fmt.Printf("Records: %d used (no limit)\n", q.Used)
+		return nil
+	}
+	fmt.Printf("Records: %d/%d used, %d available\n", q.Used, q.Limit, q.Limit-q.Used)
+	return nil
+}
+
+// warnQuota prints a warning when adding count records would approach or
+// exceed the server's limit. Servers without a quota endpoint are ignored.
+func warnQuota(count int) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return
+	}
+	q, err := fetchQuota(cfg)
+	if err != nil || q.Limit <= 0 {
+		return
+	}
+
+	after := q.Used + count
+	switch {
+	case after > q.Limit:
+		fmt.Fprintf(os.Stderr, "dnscli: warning: adding %d records exceeds the server limit (%d/%d used)\n", count, q.Used, q.Limit)
+	case float64(after) >= float64(q.Limit)*quotaWarnRatio:
+		fmt.Fprintf(os.Stderr, "dnscli: warning: approaching the server limit (%d/%d after this operation)\n", after, q.Limit)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const replHelp = `Commands:
+    list [pattern]           list records, optionally filtered like --filter
+    get <domain>             print the addresses of a domain
+    add <domain> <ip>        add a record
+    update <domain> <ip>     point a domain at a new address
+    delete <domain> [ip]     delete a domain, or only one of its addresses
+    help                     show this help
+    quit                     leave (also exit or Ctrl-D)`
+
+// runREPL reads commands from stdin until quit or end of input, running
+// each through the same functions as the matching command-line flags. The
+// configuration is loaded once and used for the whole session. Failed
+// commands are reported and the session continues.
+func runREPL() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	sessionConfig = &cfg
+
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		fmt.Printf("Connected to %s, type 'help' for commands\n", cfg.Server)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("dnscli> ")
+		}
+		line, err := reader.ReadString('\n')
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			if fields[0] == "quit" || fields[0] == "exit" {
+				return nil
+			}
+			if err := replCommand(reader, fields[0], fields[1:]); err != nil {
+				printError(err)
+			}
+		}
+		if err == io.EOF {
+			if interactive {
+				fmt.Println()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func replCommand(reader *bufio.Reader, name string, args []string) error {
+	switch name {
+	case "help", "?":
+		fmt.Println(replHelp)
+		return nil
+
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: list [pattern]")
+		}
+		*optFilter = ""
+		if len(args) == 1 {
+			*optFilter = args[0]
+		}
+		return makeRequest("GET", "/dns", nil)
+
+	case "get":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: get <domain>")
+		}
+		return getRecord(qualifyDomain(args[0]))
+
+	case "add":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: add <domain> <ip>")
+		}
+		r, err := batchRecord(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if !*optMerge {
+			if err := checkExistingDomain(r.Domain, r.IP); err != nil {
+				return err
+			}
+		}
+		warnQuota(1)
+		return makeRequest("POST", "/dns", r)
+
+	case "update":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: update <domain> <ip>")
+		}
+		r, err := batchRecord(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		return makeRequest("PUT", "/dns", Record{Domain: r.Domain, NewIP: r.IP})
+
+	case "delete":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: delete <domain> [ip]")
+		}
+		ip := ""
+		if len(args) == 2 {
+			ip = args[1]
+		}
+		r, err := batchRecord(args[0], ip)
+		if err != nil {
+			return err
+		}
+		if !*optYes && !*optDryRun && isTerminal(os.Stdin) {
+			target := r.Domain + " (all addresses)"
+			if r.IP != "" {
+				target = r.Domain + " -> " + r.IP
+			}
+			if !confirm(reader, fmt.Sprintf("Delete %s? [y/N]: ", target)) {
+				fmt.Println("Nothing deleted")
+				return nil
+			}
+		}
+		return makeRequest("DELETE", "/dns", r)
+	}
+	return fmt.Errorf("unknown command %q, type 'help' for a list", name)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// requestLog records every HTTP exchange when --log-file is given. It is
+// appended to, never truncated, so one file can collect many runs.
+var requestLog *log.Logger
+
+func openRequestLog(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %v", err)
+	}
+	requestLog = log.New(file, "", log.LstdFlags|log.Lmicroseconds)
+	return nil
+}
+
+// logExchange writes one request and its outcome to the request log. The
+// API key is always masked, whatever --show-secrets says, since log files
+// tend to be attached to bug reports.
+func logExchange(method, url string, data []byte, authMode string, start time.Time, status string, body []byte, err error) {
+	if requestLog == nil {
+		return
+	}
+	name, _ := authHeader(authMode, "")
+	requestLog.Printf("> %s %s", method, url)
+	requestLog.Printf("> %s: <redacted>", name)
+	if len(data) > 0 {
+		requestLog.Printf("> %s", data)
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		requestLog.Printf("! %v (%s)", err, elapsed)
+		return
+	}
+	requestLog.Printf("< %s (%s)", status, elapsed)
+	if text := strings.TrimSpace(string(body)); text != "" {
+		requestLog.Printf("< %s", text)
+	}
+}
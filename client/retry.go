@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryDelay is the pause before the first retry. It doubles with every
+// further attempt, up to maxRetryDelay.
+const (
+	retryDelay    = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// retryStatusCodes holds the statuses set with --retry-status. When it is
+// empty every 5xx response is retried.
+var retryStatusCodes map[int]bool
+
+func parseStatusCodes(list string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid retry status %q, expected a 4xx or 5xx code", field)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+// backoff returns the pause before retrying after the given attempt.
+func backoff(attempt int) time.Duration {
+	delay := retryDelay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+func retryableStatus(code int) bool {
+	if len(retryStatusCodes) > 0 {
+		return retryStatusCodes[code]
+	}
+	return code >= 500
+}
+
+// sendWithRetry performs doRequest, repeating it up to --retries times with
+// exponential backoff on connection errors and retryable status codes. 4xx
+// responses are only retried when listed with --retry-status.
+func sendWithRetry(ctx context.Context, client *http.Client, method, url string, data []byte, cfg Config, apiKey string) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		resp, body, err := doRequest(ctx, client, method, url, data, cfg, apiKey)
+		if attempt >= *optRetries || (err == nil && !retryableStatus(resp.StatusCode)) {
+			return resp, body, err
+		}
+		delay := backoff(attempt)
+		if *flagVerbose {
+			reason := fmt.Sprint(err)
+			if err == nil {
+				reason = resp.Status
+			}
+			fmt.Fprintf(os.Stderr, "* attempt %d of %d failed (%s), retrying in %s\n", attempt+1, *optRetries+1, reason, delay)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// rotateKey switches the configured API key to newKey after a test request
+// authenticates with it. The old key is kept as PreviousAPIKey so requests
+// can fall back to it; calling rotateKey without a new key confirms the
+// rotation and discards the old key.
+func rotateKey(newKey string) error {
+	if *optAPIKey != "" {
+		return fmt.Errorf("the API key was given with --apikey, there is no saved key to rotate")
+	}
+	if os.Getenv("DNSCLI_APIKEY") != "" {
+		return fmt.Errorf("the API key comes from DNSCLI_APIKEY, update the environment instead")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return configError(err)
+	}
+	stored, err := loadProfile()
+	if err != nil {
+		return err
+	}
+	if *optAPIKeyFile != "" || stored.APIKeyFile != "" {
+		return fmt.Errorf("the API key is read from a file, update that file instead")
+	}
+
+	if newKey == "" {
+		if cfg.PreviousAPIKey == "" {
+			return fmt.Errorf("no rotation in progress, use --rotate-key --new-key <key>")
+		}
+		// Without the fallback, so that only the current key is tested.
+		current := cfg
+		current.PreviousAPIKey = ""
+		if _, err := fetchRecords(current); err != nil {
+			return fmt.Errorf("current API key does not authenticate, keeping previous key: %w", err)
+		}
+		stored.PreviousAPIKey = ""
+		if err := saveConfig(stored); err != nil {
+			return fmt.Errorf("failed to save configuration: %v", err)
+		}
+		fmt.Println("✓ Key rotation confirmed, previous key removed")
+		return nil
+	}
+
+	test := cfg
+	test.APIKey = newKey
+	test.PreviousAPIKey = ""
+	if _, err := fetchRecords(test); err != nil {
+		return fmt.Errorf("new API key was not accepted, configuration unchanged: %w", err)
+	}
+
+	stored.PreviousAPIKey = stored.APIKey
+	stored.APIKey = newKey
+	if err := saveConfig(stored); err != nil {
+		return fmt.Errorf("failed to save configuration: %v", err)
+	}
+
+	fmt.Println("✓ New API key verified and saved")
+	fmt.Println("The previous key is kept as a fallback; run 'dnscli --rotate-key' to confirm the rotation.")
+	return nil
+}
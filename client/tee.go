@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// teeStdout copies everything later written to stdout into the file at path
+// as well, truncating it first unless appending. The returned function
+// flushes the copy and must be called before the process exits.
+//
+// Stdout becomes a pipe, so automatic color and paging are turned off. Color
+// forced with --color always still reaches the terminal, but is stripped from
+// the copy in the file.
+func teeStdout(path string, appending bool) (func(), error) {
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, mode, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write output file: %v", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(stdout, &escapeStripper{w: file}), r)
+		close(done)
+	}()
+
+	return func() {
+		w.Close()
+		<-done
+		os.Stdout = stdout
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "dnscli: failed to write %s: %v\n", path, err)
+		}
+	}, nil
+}
+
+// escapeStripper writes to w with ANSI escape sequences (ESC [ ... final
+// byte) removed. It keeps its state between writes, since the pipe may split
+// a sequence.
+type escapeStripper struct {
+	w     io.Writer
+	state int // 0 text, 1 after ESC, 2 inside the sequence
+}
+
+func (e *escapeStripper) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch e.state {
+		case 0:
+			if b == 0x1b {
+				e.state = 1
+			} else {
+				out = append(out, b)
+			}
+		case 1:
+			if b == '[' {
+				e.state = 2
+			} else {
+				e.state = 0
+				out = append(out, b)
+			}
+		case 2:
+			if b >= 0x40 && b <= 0x7e {
+				e.state = 0
+			}
+		}
+	}
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// transformRecord pipes r as JSON through the shell command and decodes the
+// rewritten record from its stdout. Empty output drops the record, which is
+// reported by a false second return value.
+func transformRecord(command string, r Record) (Record, bool, error) {
+	input, err := json.Marshal(r)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Record{}, false, fmt.Errorf("transform failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if len(output) == 0 {
+		return Record{}, false, nil
+	}
+
+	var out Record
+	if err := json.Unmarshal(output, &out); err != nil {
+		return Record{}, false, fmt.Errorf("transform returned invalid JSON: %v", err)
+	}
+	return out, true, nil
+}
+
+func transformRecords(command string, records []Record) ([]Record, error) {
+	var kept []Record
+	for i, r := range records {
+		out, ok, err := transformRecord(command, r)
+		if err != nil {
+			return nil, fmt.Errorf("record %d (%s): %v", i+1, r.Domain, err)
+		}
+		if ok {
+			kept = append(kept, out)
+		}
+	}
+	return kept, nil
+}
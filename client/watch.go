@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// watchList re-renders the record list every interval until interrupted.
+// A failed poll is shown in place of the table and polling continues.
+func watchList(interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fmt.Print(clearScreen)
+		fmt.Printf("Every %s: dnscli --list    %s\n\n", interval, time.Now().Format("2006-01-02 15:04:05"))
+		if err := makeRequest("GET", "/dns", nil); err != nil {
+			fmt.Println(colorize(colorRed, fmt.Sprintf("Error: %v", err)))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		}
+	}
+}
@@ -8,8 +8,15 @@ import os
 from threading import Lock
 from flask import Flask, request, jsonify, abort
 
+VERSION = "1.0.0"
 API_KEY = os.getenv("API_KEY", "6208de06706682ba75ffe49a2b458af0")
 LOG_FILE = "/var/log/dns_api.log"
+MAX_RECORDS = int(os.getenv("MAX_RECORDS", "0"))
+DNSMASQ_CONF = os.getenv("DNSMASQ_CONF", "/etc/dnsmasq.d/dnsmassq-api.conf")
+
+# Record types that dnsmasq cannot take as address= entries, with the option
+# that holds them in DNSMASQ_CONF.
+EXTRA_TYPES = {"CNAME": "cname", "TXT": "txt-record", "PTR": "ptr-record"}
 
 RE_DOMAIN = re.compile(r"^(?:[a-zA-Z0-9-]+\.)*[a-zA-Z0-9-]+$")
 RE_IP = re.compile(r"^(?:\d{1,3}\.){3}\d{1,3}$")
@@ -34,6 +41,15 @@ def validate_ip(ip):
     parts = ip.split(".")
     return all(0 <= int(p) <= 255 for p in parts)
 
+def validate_value(rtype, value):
+    if rtype in ("", "A", "AAAA"):
+        return validate_ip(value)
+    if rtype in ("CNAME", "PTR"):
+        return validate_domain(value)
+    if rtype == "TXT":
+        return len(value) <= 255 and not any(c in value for c in '"\\\n')
+    return False
+
 def run_cmd(args):
     try:
         r = subprocess.run(args, stdout=subprocess.PIPE, stderr=subprocess.PIPE, text=True)
@@ -76,17 +92,86 @@ def get_records():
             logging.warning(f"Failed to parse line: {line} - {e}")
             continue
     
-    return records, None
+    return records + read_extra_records(), None
+
+def read_extra_records():
+    options = {option: rtype for rtype, option in EXTRA_TYPES.items()}
+    try:
+        with open(DNSMASQ_CONF) as f:
+            lines = f.read().splitlines()
+    except FileNotFoundError:
+        return []
+
+    records = []
+    for line in lines:
+        option, sep, value = line.partition("=")
+        name, comma, target = value.partition(",")
+        if not sep or not comma or option not in options:
+            continue
+        records.append({"domain": name, "ip": target.strip('"'), "type": options[option]})
+    return records
+
+def write_extra_records(records):
+    lines = []
+    for r in records:
+        value = '"' + r["ip"] + '"' if r["type"] == "TXT" else r["ip"]
+        lines.append(f"{EXTRA_TYPES[r['type']]}={r['domain']},{value}\n")
+    os.makedirs(os.path.dirname(DNSMASQ_CONF), exist_ok=True)
+    tmp = DNSMASQ_CONF + ".tmp"
+    with open(tmp, "w") as f:
+        f.writelines(lines)
+    os.replace(tmp, DNSMASQ_CONF)
+
+def add_record(domain, value, rtype):
+    if rtype in EXTRA_TYPES:
+        write_extra_records(read_extra_records() + [{"domain": domain, "ip": value, "type": rtype}])
+        return True
+    rc, _, err = run_cmd(["uci", "add_list", f"dhcp.@dnsmasq[0].address=/{domain}/{value}"])
+    if rc != 0:
+        raise OSError(err)
+    return False
+
+def remove_records(matches):
+    extra = [r for r in matches if "type" in r]
+    for r in matches:
+        if "type" not in r:
+            run_cmd(["uci", "del_list", f"dhcp.@dnsmasq[0].address=/{r['domain']}/{r['ip']}"])
+    if extra:
+        write_extra_records([r for r in read_extra_records() if r not in extra])
+    return bool(extra)
+
+def apply_changes(extra_changed):
+    run_cmd(["uci", "commit", "dhcp"])
+    # dnsmasq only reads its configuration files on a full restart.
+    run_cmd(["/etc/init.d/dnsmasq", "restart" if extra_changed else "reload"])
 
 @app.before_request
 def auth_check():
-    if request.path != "/health":
+    if request.path not in ("/health", "/version"):
         check_auth()
 
 @app.route("/health")
 def health():
     return {"status": "ok"}
 
+@app.route("/version")
+def server_version():
+    return {"version": VERSION}
+
+@app.route("/domain")
+def local_domain():
+    rc, out, _ = run_cmd(["uci", "-q", "get", "dhcp.@dnsmasq[0].domain"])
+    if rc != 0:
+        return {"domain": ""}
+    return {"domain": out}
+
+@app.route("/quota")
+def quota():
+    records, err = get_records()
+    if records is None:
+        return {"error": err}, 500
+    return {"used": len(records), "limit": MAX_RECORDS}
+
 @app.route("/dns", methods=["GET"])
 def list_dns():
     records, err = get_records()
@@ -94,44 +179,58 @@ def list_dns():
         return {"error": err}, 500
     return {"records": records}
 
+@app.route("/dns/<domain>", methods=["GET"])
+def get_dns(domain):
+    records, err = get_records()
+    if records is None:
+        return {"error": err}, 500
+    matches = [r for r in records if r["domain"].lower() == domain.lower()]
+    if not matches:
+        return {"error": "not found"}, 404
+    return {"records": matches}
+
 @app.route("/dns", methods=["POST"])
 def add_dns():
     data = request.get_json(force=True)
     domain = data.get("domain", "").strip()
     ip = data.get("ip", "").strip()
+    rtype = data.get("type", "").strip().upper()
 
     if not domain or not ip:
         return {"error": "domain and ip required"}, 400
-    if not validate_domain(domain) or not validate_ip(ip):
+    if not validate_domain(domain) or not validate_value(rtype, ip):
         return {"error": "invalid format"}, 400
-
-    entry = f"/{domain}/{ip}"
+    if rtype == "PTR" and not domain.lower().endswith((".in-addr.arpa", ".ip6.arpa")):
+        return {"error": "PTR records must be named under in-addr.arpa or ip6.arpa"}, 400
 
     with lock:
         records, _ = get_records()
         if any(r["domain"] == domain and r["ip"] == ip for r in records):
             return {"status": "exists"}
+        if MAX_RECORDS and len(records) >= MAX_RECORDS:
+            return {"error": "record limit reached"}, 507
 
-        rc, _, err = run_cmd(["uci", "add_list", f"dhcp.@dnsmasq[0].address={entry}"])
-        if rc != 0:
-            return {"error": "add failed", "detail": err}, 500
-
-        run_cmd(["uci", "commit", "dhcp"])
-        run_cmd(["/etc/init.d/dnsmasq", "reload"])
+        try:
+            extra_changed = add_record(domain, ip, rtype)
+        except OSError as e:
+            return {"error": "add failed", "detail": str(e)}, 500
+        apply_changes(extra_changed)
 
     logging.info(f"Added {domain} -> {ip}")
     return {"status": "added", "domain": domain, "ip": ip}
 
-@app.route("/dns", methods=["PUT"])
+@app.route("/dns", methods=["PUT", "PATCH"])
 def update_dns():
     data = request.get_json(force=True)
     domain = data.get("domain", "").strip()
     ip = data.get("ip", "").strip()
     new_ip = data.get("new_ip", "").strip()
+    current_ip = data.get("current_ip", "").strip()
+    rtype = data.get("type", "").strip().upper()
 
     if not domain or not new_ip:
         return {"error": "domain and new_ip required"}, 400
-    if not validate_domain(domain) or not validate_ip(new_ip):
+    if not validate_domain(domain):
         return {"error": "invalid format"}, 400
 
     with lock:
@@ -139,18 +238,25 @@ def update_dns():
         matches = [r for r in records if r["domain"] == domain and (not ip or r["ip"] == ip)]
         if not matches:
             return {"error": "not found"}, 404
+        if current_ip and {r["ip"] for r in matches} != {current_ip}:
+            current = ", ".join(r["ip"] for r in matches)
+            return {"error": f"{domain} resolves to {current}, not {current_ip}"}, 409
 
-        for r in matches:
-            entry = f"/{r['domain']}/{r['ip']}"
-            run_cmd(["uci", "del_list", f"dhcp.@dnsmasq[0].address={entry}"])
+        # Without a type the record keeps the type it had.
+        new_type = rtype or matches[0].get("type", "")
+        if not validate_value(new_type, new_ip):
+            return {"error": "invalid format"}, 400
 
-        new_entry = f"/{domain}/{new_ip}"
-        run_cmd(["uci", "add_list", f"dhcp.@dnsmasq[0].address={new_entry}"])
-        run_cmd(["uci", "commit", "dhcp"])
-        run_cmd(["/etc/init.d/dnsmasq", "reload"])
+        try:
+            extra_changed = remove_records(matches)
+            extra_changed = add_record(domain, new_ip, new_type) or extra_changed
+        except OSError as e:
+            return {"error": "update failed", "detail": str(e)}, 500
+        apply_changes(extra_changed)
 
-    logging.info(f"Updated {domain} -> {new_ip}")
-    return {"status": "updated", "domain": domain, "new_ip": new_ip}
+    old_ips = ", ".join(r["ip"] for r in matches)
+    logging.info(f"Updated {domain}: {old_ips} -> {new_ip}")
+    return {"status": "updated", "domain": domain, "ip": old_ips, "new_ip": new_ip}
 
 @app.route("/dns", methods=["DELETE"])
 def delete_dns():
@@ -169,12 +275,11 @@ def delete_dns():
         if not matches:
             return {"error": "not found"}, 404
 
-        for r in matches:
-            entry = f"/{r['domain']}/{r['ip']}"
-            run_cmd(["uci", "del_list", f"dhcp.@dnsmasq[0].address={entry}"])
-
-        run_cmd(["uci", "commit", "dhcp"])
-        run_cmd(["/etc/init.d/dnsmasq", "reload"])
+        try:
+            extra_changed = remove_records(matches)
+        except OSError as e:
+            return {"error": "delete failed", "detail": str(e)}, 500
+        apply_changes(extra_changed)
 
     logging.info(f"Deleted {domain}")
     return {"status": "deleted", "domain": domain}